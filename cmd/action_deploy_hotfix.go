@@ -4,58 +4,47 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"regexp"
-	"strings"
+	"io"
+	"os"
 
 	"log/slog"
 
 	"github.com/urfave/cli/v3"
 	"github.com/wayan/mergeexp/git"
 	"github.com/wayan/mergeexp/gitdir"
+	"github.com/wayan/mergeexp/gitlab"
 	"github.com/wayan/oc-mergexp-gl/cmd/flags"
 )
 
-// deployHotfixReleaseMessage creates new empty commit with changes from previous release
-func deployHotfixReleaseMessage(gd *gitdir.Dir, newRelease, prevRelease, newTag string) (string, error) {
-	cmd := gd.Command("git", "log", "--merges", `--pretty=format:%x00%h%x00%s%x00%B%x00`, prevRelease+".."+newRelease)
-	out, err := cmd.Output()
-	if err != nil {
-		return "", err
+func ActionDeployHotfix(ctx context.Context, cmd *cli.Command) error {
+	// a mistyped subcommand would be taken as an argument and the action run
+	if cmd.Args().Present() {
+		return fmt.Errorf("unexpected argument %q", cmd.Args().First())
+	}
+	workdir := cmd.String(flags.Workdir)
+	if workdir == "" {
+		return fmt.Errorf("no workdir set to build the branches")
 	}
-	var result = fmt.Sprintf("Release %s\n\nChangelog:\n", newTag)
-	for parts := strings.Split(string(out), "\x00"); len(parts) >= 4; parts = parts[4:] {
-		// index 0 is ignored it is either empty string or new line
-		if line := deployHotfixReleaseMessageLine(parts[1], parts[2], parts[3]); line != "" {
-			result += line + "\n"
+	gitLabKey := cmd.String(flags.DeployKey)
+	if gitLabKey != "" {
+		if err := checkDeployKey(gitLabKey, flags.DeployKey); err != nil {
+			return err
 		}
 	}
-	return result, nil
-}
-
-func deployHotfixReleaseMessageLine(hash, subject, body string) string {
-	// if there is line starting with OMCTR- we use it as subject
-	if matches := regexp.MustCompile(`(?m)^(OMCTR-.*)$`).FindStringSubmatch(body); matches != nil {
-		subject = matches[1]
+	productionKey := cmd.String(flags.ProductionDeployKey)
+	if productionKey != "" {
+		if err := checkDeployKey(productionKey, flags.ProductionDeployKey); err != nil {
+			return err
+		}
 	}
-
-	if matches := regexp.MustCompile(`See merge request ((\w+/.*?)!(\d+))`).FindStringSubmatch(body); matches != nil {
-		const baseUrl = "https://gitlab.services.itc.st.sk"
-
-		mr := matches[1]
-		part := matches[2]
-		mrid := matches[3]
-		//		* 28e6b06f4 OMCTR-14357: HOTFIX - zakládání GP do PK - xml set [b2btmcz/gts-ocp!35] https://gitlab.services.itc.st.sk/b2btmcz/gts-ocp/-/merge_requests/35
-		url := fmt.Sprintf("%s/%s/-/merge_requests/%s", baseUrl, part, mrid)
-		return fmt.Sprintf("* %s %s [%s] %s", hash, subject, mr, url)
+	ids, err := sshIdentitiesFromFlag(cmd)
+	if err != nil {
+		return err
 	}
-	// without merge request
-	return fmt.Sprintf("* %s %s", hash, subject)
-}
-
-func ActionDeployHotfix(ctx context.Context, cmd *cli.Command) error {
-	workdir := cmd.String(flags.Workdir)
-	if workdir == "" {
-		return fmt.Errorf("no workdir set to build the branches")
+	// resolved before anything is pushed, the release is created at the end
+	privateToken, err := resolvePrivateToken(ctx, cmd)
+	if err != nil {
+		return err
 	}
 	if err := createDirIfNotExists(workdir); err != nil {
 		return err
@@ -70,17 +59,29 @@ func ActionDeployHotfix(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
+	lock, err := lockWorkdir(ctx, gd.Dir, cmd.Bool(flags.Wait))
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	if err := preflight(gd, !cmd.Bool(flags.NoAutoRecover)); err != nil {
+		return err
+	}
+
+	// gdGitLab is working dir with ssh identity for GitLab: the mapped one or the deploy key (if any)
 	sshURL := cmd.String(flags.TargetProjectSSHURL)
-	masterSHA, err := git.LsRemote(gd, sshURL, Master)
+	gdGitLab := ids.dir(gd, sshURL, gitLabKey)
+	masterSHA, err := git.LsRemote(gdGitLab, sshURL, Master)
 	if err != nil {
 		return err
 	}
 
-	if err := fetchSHA(gd, sshURL, masterSHA); err != nil {
+	if err := fetchSHA(gdGitLab, sshURL, masterSHA); err != nil {
 		return err
 	}
 
-	tag, err := git.HighestVersionTag(gd, sshURL)
+	tag, err := git.HighestVersionTag(gdGitLab, sshURL)
 	if err != nil {
 		return err
 	}
@@ -95,7 +96,14 @@ func ActionDeployHotfix(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	if tag.SHA == masterSHA {
+	var gitLabPushes []push
+	var harmonization *developHarmonization
+	var releaseMessage string
+	var changelog []changelogEntry
+	prevRelease, prevReleaseSHA := tag.String(), tag.SHA
+	// explicitly requested release is created even if master is already tagged
+	forceRelease := cmd.IsSet(flags.Bump) || cmd.IsSet(flags.ReleaseVersion)
+	if tag.SHA == masterSHA && !forceRelease {
 		slog.Info("master was already tagged by the highest version", "tag", tag.String())
 	} else {
 		// we create local master
@@ -103,26 +111,28 @@ func ActionDeployHotfix(ctx context.Context, cmd *cli.Command) error {
 			return err
 		}
 
-		prevRelease := tag.String()
-		tag.Patch++
-		msg, err := deployHotfixReleaseMessage(gd, masterSHA, prevRelease, tag.String())
+		next, err := nextReleaseVersion(*tag, cmd.String(flags.Bump), cmd.String(flags.ReleaseVersion))
 		if err != nil {
 			return err
 		}
-
-		// create empty release commit
-		if err := gd.Command("git", "commit", "-m", msg, "--allow-empty").Run(); err != nil {
+		tag = &next
+		changelog, err = deployHotfixChangelog(gd, deployHotfixChangelogFormat(cmd), masterSHA, prevRelease)
+		if err != nil {
 			return err
 		}
+		releaseMessage = deployHotfixReleaseMessage(tag.String(), changelog)
 
-		out, err := gd.Command("git", "rev-parse", "HEAD").Output()
-		if err != nil {
+		// create empty release commit
+		if err := gd.Command("git", "commit", "-m", releaseMessage, "--allow-empty").Run(); err != nil {
 			return err
 		}
 
 		// new master
 		origMasterSHA := masterSHA
-		masterSHA = strings.TrimSpace(string(out))
+		masterSHA, err = revParse(gd, "HEAD")
+		if err != nil {
+			return err
+		}
 
 		slog.Info("tagging master", "tag", tag.String())
 		if err := gd.Command("git", "tag", "-f", tag.String(), masterSHA).Run(); err != nil {
@@ -130,72 +140,162 @@ func ActionDeployHotfix(ctx context.Context, cmd *cli.Command) error {
 			return err
 		}
 
-		// pushing new master back to GitLab
-		if err := gd.Command("git", "push", sshURL, masterSHA+":"+Master).Run(); err != nil {
-			return err
-		}
-
-		// pushing tag to gitLab
-		if err := gd.Command("git", "push", sshURL, tag.String()).Run(); err != nil {
-			return err
+		// pushing new master and the tag back to GitLab
+		gitLabPushes = []push{
+			{Name: "GitLab", URL: sshURL, Refspec: masterSHA + ":" + Master, SSH: ids.identity(sshURL, gitLabKey)},
+			{Name: "GitLab", URL: sshURL, Refspec: tag.String(), SSH: ids.identity(sshURL, gitLabKey)},
 		}
 
 		// harmonization of develop branch, merging second parent of the original masterSHA
-		// works for OCP only
-		if developBranch := cmd.String(flags.DevelopBranch); developBranch != "" {
-			if err := harmonizeDevelop(ctx, gd, sshURL, origMasterSHA, developBranch); err != nil {
+		// works for OCP only, already tagged master was harmonized with the previous release
+		if developBranch := cmd.String(flags.DevelopBranch); developBranch != "" && origMasterSHA != prevReleaseSHA {
+			harmonization, err = planHarmonizeDevelop(gdGitLab, sshURL, origMasterSHA, developBranch)
+			if err != nil {
 				return err
 			}
 		}
+		if harmonization != nil {
+			// merged locally, develop is pushed after master and the tag, before production
+			if err := harmonization.merge(gd); err != nil {
+				return err
+			}
+			gitLabPushes = append(gitLabPushes, harmonization.push(ids.identity(sshURL, gitLabKey)))
+		}
 	}
 
 	// pushing to production
 	productionURL := cmd.String(flags.ProductionURL)
 	productionBranch := cmd.String(flags.ProductionBranch)
-	if err := gd.Command("git", "push", productionURL, tag.String()).Run(); err != nil {
+	productionPushes := []push{
+		{Name: "production", URL: productionURL, Refspec: tag.String(), SSH: ids.identity(productionURL, productionKey)},
+		{Name: "production", URL: productionURL, Refspec: masterSHA + ":" + "refs/heads/" + productionBranch, SSH: ids.identity(productionURL, productionKey)},
+	}
+
+	if cmd.Bool(flags.DryRun) {
+		slog.Info("dry run, nothing is pushed")
+		return printDeployHotfixPlan(os.Stdout, gd, prevRelease, tag.String(), releaseMessage, changelog, harmonization, append(gitLabPushes, productionPushes...))
+	}
+
+	// GitLab first including develop harmonization, production gets the release only when it is in GitLab
+	timeout := cmd.Duration(flags.PushTimeout)
+	results := runPushGroups(ctx, gd, timeout, [][]push{gitLabPushes})
+	if err := pushResultsErr(results); err != nil {
+		printPushSummary(os.Stdout, results)
 		return err
 	}
-	if err := gd.Command("git", "push", productionURL, masterSHA+":"+"refs/heads/"+productionBranch).Run(); err != nil {
+	results = append(results, runPushGroups(ctx, gd, timeout, [][]push{productionPushes})...)
+	printPushSummary(os.Stdout, results)
+	if err := pushResultsErr(results); err != nil {
 		return err
 	}
 
+	if releaseMessage != "" {
+		if privateToken == "" {
+			slog.Warn("no private token for GitLab REST API, GitLab release is not created", "tag", tag.String())
+			return nil
+		}
+		rc, err := buildResty(cmd, privateToken)
+		if err != nil {
+			return err
+		}
+		gc := gitlab.NewClient(rc)
+		if err := createRelease(ctx, gc, cmd.Int(flags.TargetProjectID), tag.String(), deployHotfixReleaseNotes(changelog)); err != nil {
+			return fmt.Errorf("creating GitLab release %s: %w", tag.String(), err)
+		}
+	}
+
 	return nil
 }
 
-func harmonizeDevelop(ctx context.Context, gd *gitdir.Dir, sshURL, masterSHA, developBranch string) error {
+// deployHotfixChangelogFormat returns changelog settings, GitLab URL defaults to the one of REST API
+func deployHotfixChangelogFormat(cmd *cli.Command) changelogFormat {
+	format := changelogFormat{
+		GitLabURL:        cmd.String(flags.GitLabURL),
+		IssueKeyPrefixes: cmd.StringSlice(flags.IssueKeyPrefix),
+	}
+	if format.GitLabURL == "" {
+		format.GitLabURL = gitLabURL(cmd.String(flags.GitLabAPIURL))
+	}
+	return format
+}
+
+// printDeployHotfixPlan prints what the deployment would do: the release, the harmonization of develop and the pushes
+func printDeployHotfixPlan(w io.Writer, gd *gitdir.Dir, prevTag, tag, releaseMessage string, changelog []changelogEntry, harmonization *developHarmonization, pushes []push) error {
+	if releaseMessage == "" {
+		fmt.Fprintf(w, "Tag: %s (master already tagged, no new release)\n\n", tag)
+	} else {
+		fmt.Fprintf(w, "Tag: %s (previous %s)\n\nRelease message:\n%s\n", tag, prevTag, releaseMessage)
+		fmt.Fprintf(w, "GitLab release notes:\n%s\n", deployHotfixReleaseNotes(changelog))
+	}
+
+	if harmonization != nil {
+		fmt.Fprintf(w, "Develop harmonization: merge %s (second parent of master) into %s at %s\n\n",
+			harmonization.secondParentSHA, harmonization.developBranch, harmonization.developSHA)
+	} else {
+		fmt.Fprintf(w, "Develop harmonization: none\n\n")
+	}
+
+	fmt.Fprintf(w, "Pushes:\n")
+	return printPushPlan(w, gd, pushes)
+}
+
+// developHarmonization is a merge of the second parent of master into develop branch
+type developHarmonization struct {
+	sshURL          string
+	developBranch   string
+	developSHA      string
+	secondParentSHA string
+}
+
+// planHarmonizeDevelop finds out whether develop branch needs to be harmonized with master,
+// returns nil if master is not a merge commit or develop already contains the second parent of master
+func planHarmonizeDevelop(gd *gitdir.Dir, sshURL, masterSHA, developBranch string) (*developHarmonization, error) {
 	// fetching HEAD^2
-	out, err := gd.Command("git", "rev-parse", "--verify", "-q", masterSHA+"^2").Output()
+	secondParentSHA, err := revParse(gd, masterSHA+"^2")
 	if err != nil {
-		return fmt.Errorf("master SHA (%s) apparently has no second parent, nothing to merge to develop", masterSHA)
+		slog.Info("master has no second parent, nothing to merge to develop", "sha", masterSHA)
+		return nil, nil
 	}
-	secondParentSHA := strings.TrimSpace(string(out))
 	developSHA, err := git.LsRemote(gd, sshURL, developBranch)
+	if err != nil {
+		return nil, err
+	}
 
-	// fetching the tag
-	if gd.Command("git", "fetch", sshURL, developSHA).Run(); err != nil {
-		return err
+	if err := fetchSHA(gd, sshURL, developSHA); err != nil {
+		return nil, err
 	}
 	slog.Info("secondParent", "sha", secondParentSHA)
 	if err := gd.Command("git", "merge-base", "--is-ancestor", secondParentSHA, developSHA).Run(); err == nil {
 		slog.Info("develop already contains second parent of master")
-		return nil
+		return nil, nil
 	}
 
-	// branch has different name
-	localBranch := developBranch + "-tmp"
-	if err := gd.StartExperimentalBranch(localBranch, developSHA); err != nil {
+	return &developHarmonization{
+		sshURL:          sshURL,
+		developBranch:   developBranch,
+		developSHA:      developSHA,
+		secondParentSHA: secondParentSHA,
+	}, nil
+}
+
+// merge merges the second parent of master into the local copy of develop branch
+func (h *developHarmonization) merge(gd *gitdir.Dir) error {
+	if err := gd.StartExperimentalBranch(h.localBranch(), h.developSHA); err != nil {
 		return err
 	}
 
-	if err := gd.Command("git", "merge", "--no-ff", "-m", "harmonization of master with develop", secondParentSHA).Run(); err != nil {
+	if err := gd.Command("git", "merge", "--no-ff", "-m", "harmonization of master with develop", h.secondParentSHA).Run(); err != nil {
 		return fmt.Errorf("merge of second parent failed: %w", err)
 	}
-
-	// pushing the develop back
-	if err := gd.Command("git", "push", sshURL, localBranch+":"+developBranch).Run(); err != nil {
-		return fmt.Errorf("push to develop failed: %w", err)
-	}
-
 	return nil
+}
+
+// push returns the push of the merged develop back to GitLab
+func (h *developHarmonization) push(id *SSHIdentity) push {
+	return push{Name: "GitLab " + h.developBranch, URL: h.sshURL, Refspec: h.localBranch() + ":" + h.developBranch, SSH: id}
+}
 
+// localBranch returns the name of the local branch with merged develop, the branch has different name
+func (h *developHarmonization) localBranch() string {
+	return h.developBranch + "-tmp"
 }
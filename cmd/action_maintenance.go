@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"log/slog"
+
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/mergeexp/gitdir"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+// ActionMaintenance cleans the work dir: resets unfinished merge, removes temporary branches and local tags,
+// expires reflogs and collects garbage
+func ActionMaintenance(ctx context.Context, cmd *cli.Command) error {
+	workdir := cmd.String(flags.Workdir)
+	if workdir == "" {
+		return fmt.Errorf("no workdir set")
+	}
+	gd, err := gitdir.New(workdir)
+	if err != nil {
+		return err
+	}
+	if err := gd.Command("git", "rev-parse", "--git-dir").Run(); err != nil {
+		return fmt.Errorf("%s is not a git repo: %w", workdir, err)
+	}
+
+	lock, err := lockWorkdir(ctx, gd.Dir, cmd.Bool(flags.Wait))
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	sizeBefore, err := dirSize(gd.Dir)
+	if err != nil {
+		return err
+	}
+
+	if err := preflight(gd, true); err != nil {
+		return err
+	}
+
+	// temporary branches like develop-tmp, HEAD is detached when it points to one of them
+	out, err := gd.Command("git", "for-each-ref", "--format=%(refname:short)", "refs/heads/*-tmp").Output()
+	if err != nil {
+		return err
+	}
+	if branches := strings.Fields(string(out)); len(branches) > 0 {
+		current, _ := gd.Command("git", "symbolic-ref", "--quiet", "--short", "HEAD").Output()
+		if strings.HasSuffix(strings.TrimSpace(string(current)), "-tmp") {
+			if err := gd.Command("git", "checkout", "--quiet", "--detach").Run(); err != nil {
+				return err
+			}
+		}
+		slog.Info("removing temporary branches", "branches", branches)
+		if err := gd.Command("git", append([]string{"branch", "-D"}, branches...)...).Run(); err != nil {
+			return err
+		}
+	}
+
+	// local tags are recreated from the remote ones on every run
+	out, err = gd.Command("git", "tag", "--list").Output()
+	if err != nil {
+		return err
+	}
+	if tags := strings.Fields(string(out)); len(tags) > 0 {
+		slog.Info("removing local tags", "count", len(tags))
+		if err := gd.Command("git", append([]string{"tag", "-d"}, tags...)...).Run(); err != nil {
+			return err
+		}
+	}
+
+	// objects of fetched merge requests and failed merges are referenced only from reflogs
+	slog.Info("expiring reflogs and collecting garbage")
+	if err := gd.Command("git", "reflog", "expire", "--expire=now", "--all").Run(); err != nil {
+		return err
+	}
+	if err := gd.Command("git", "gc", "--prune=now", "--quiet").Run(); err != nil {
+		return err
+	}
+
+	sizeAfter, err := dirSize(gd.Dir)
+	if err != nil {
+		return err
+	}
+	freed := max(sizeBefore-sizeAfter, 0)
+	fmt.Printf("%s: %s before, %s after, %s freed\n", gd.Dir,
+		formatSize(sizeBefore), formatSize(sizeAfter), formatSize(freed))
+	return nil
+}
+
+// dirSize returns size of all files in the directory
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				// removed by gc meanwhile
+				return nil
+			}
+			return err
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size, err
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	value := float64(size)
+	for _, suffix := range []string{"KiB", "MiB", "GiB"} {
+		value /= unit
+		if value < unit || suffix == "GiB" {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+	}
+	return ""
+}
@@ -2,15 +2,11 @@ package cmd
 
 import (
 	"context"
-	"crypto/tls"
-	"errors"
 	"fmt"
 	"os"
-	"slices"
 
 	"log/slog"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/urfave/cli/v3"
 	"github.com/wayan/mergeexp/gitdir"
 	"github.com/wayan/mergeexp/gitlab"
@@ -18,17 +14,11 @@ import (
 	"github.com/wayan/oc-mergexp-gl/cmd/flags"
 )
 
-func buildResty(cmd *cli.Command) (*resty.Client, error) {
-	rc := resty.New()
-	rc.SetBaseURL(cmd.String(flags.GitLabAPIURL))
-	rc.SetHeader("PRIVATE-TOKEN", cmd.String(flags.PrivateToken))
-	rc.SetTLSClientConfig(&tls.Config{
-		InsecureSkipVerify: true, // WARNING: Do NOT use in production unless you know the risks!
-	})
-	return rc, nil
-}
-
 func ActionMergexp(ctx context.Context, cmd *cli.Command) error {
+	// a mistyped subcommand would be taken as an argument and the action run
+	if cmd.Args().Present() {
+		return fmt.Errorf("unexpected argument %q", cmd.Args().First())
+	}
 	workdir := cmd.String(flags.Workdir)
 	if workdir == "" {
 		return fmt.Errorf("no workdir set to build the branches")
@@ -36,13 +26,24 @@ func ActionMergexp(ctx context.Context, cmd *cli.Command) error {
 	if err := createDirIfNotExists(workdir); err != nil {
 		return err
 	}
-	privateToken := cmd.String(flags.PrivateToken)
+	privateToken, err := resolvePrivateToken(ctx, cmd)
+	if err != nil {
+		return err
+	}
 	if privateToken == "" {
-		return errors.New("no private token for access to GitLab REST API")
+		return fmt.Errorf("no private token for access to GitLab REST API, set %s, %s or %s", flags.PrivateToken, flags.PrivateTokenFile, flags.PrivateTokenCommand)
+	}
+	targets, err := selectDeployTargets(cmd.StringSlice(flags.DeployTarget), cmd.StringSlice(flags.OnlyDeployTarget))
+	if err != nil {
+		return err
 	}
 	deployKey := cmd.String(flags.DeployKey)
-	if _, err := os.Stat(deployKey); errors.Is(err, os.ErrNotExist) {
-		return fmt.Errorf("file %s with deployment key does not exist, either create it or set different name (see deploy-key option)", deployKey)
+	if err := checkDeployKey(deployKey, flags.DeployKey); err != nil {
+		return err
+	}
+	ids, err := sshIdentitiesFromFlag(cmd)
+	if err != nil {
+		return err
 	}
 
 	gd, err := gitdir.New(workdir)
@@ -55,29 +56,53 @@ func ActionMergexp(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	rc, err := buildResty(cmd)
+	lock, err := lockWorkdir(ctx, gd.Dir, cmd.Bool(flags.Wait))
+	if err != nil {
+		return err
+	}
+	defer lock.unlock()
+
+	if err := preflight(gd, !cmd.Bool(flags.NoAutoRecover)); err != nil {
+		return err
+	}
+
+	rc, err := buildResty(cmd, privateToken)
 	if err != nil {
 		return err
 	}
 
 	gc := gitlab.NewClient(rc)
 	targetProjectID := cmd.Int(flags.TargetProjectID)
-	mrs, err := gc.MergeRequests(ctx, targetProjectID)
+	mrs, err := fetchMergeRequests(ctx, gc, targetProjectID)
 	if err != nil {
 		return err
 	}
 
+	var filters []mergeRequestFilter
+	if !cmd.Bool(flags.IncludeDrafts) {
+		filters = append(filters, draftFilter)
+	}
 	if idsToSkip := cmd.IntSlice(flags.SkipMergeRequests); len(idsToSkip) > 0 {
 		slog.Info("skipping", "idsToSkip", idsToSkip)
-		skipped := func(mr gitlab.MergeRequest) bool {
-			for _, id := range idsToSkip {
-				if id == mr.ID {
-					return true
-				}
-			}
-			return false
+		filters = append(filters, skipIDsFilter(idsToSkip))
+	}
+	if labels := cmd.StringSlice(flags.IncludeLabels); len(labels) > 0 {
+		filters = append(filters, includeLabelsFilter(labels))
+	}
+	if labels := cmd.StringSlice(flags.ExcludeLabels); len(labels) > 0 {
+		filters = append(filters, excludeLabelsFilter(labels))
+	}
+	mrs, skipped := filterMergeRequests(mrs, filters...)
+
+	// pipelines are checked only for merge requests which passed the other filters
+	if cmd.Bool(flags.RequirePipelineSuccess) {
+		statuses, err := headPipelineStatuses(ctx, gc, mrs)
+		if err != nil {
+			return err
 		}
-		mrs = slices.DeleteFunc(mrs, skipped)
+		var skippedPipeline []skippedMergeRequest
+		mrs, skippedPipeline = filterMergeRequests(mrs, pipelineFilter(statuses))
+		skipped = append(skipped, skippedPipeline...)
 	}
 
 	slog.Info("Merging pull requests")
@@ -87,17 +112,14 @@ func ActionMergexp(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	sshURL, err := gc.ProjectSSHUrl(ctx, targetProjectID)
+	projectURLs := newProjectSSHURLs(gc)
+	sshURL, err := projectURLs.get(ctx, targetProjectID)
 	if err != nil {
 		return err
 	}
 
-	// gdFetch is working dir with deploy key set
-	// GIT_SSH_COMMAND must be at the end of the settings
-	// when run go run the GIT_SSH_COMMAND is already set as GIT_SSH_COMMAND=ssh -o ControlMaster=no -o BatchMode=yes
-	gdFetch, err := gitdir.New(workdir)
-	gdFetch.Env = append(os.Environ(), "GIT_SSH_COMMAND=ssh -o ControlMaster=no -o BatchMode=yes -o IdentitiesOnly=yes -i "+deployKey)
-	if err := fetchSHA(gdFetch, sshURL, sha); err != nil {
+	// GitLab is fetched with the deploy key unless there is ssh identity for the URL
+	if err := fetchSHA(ids.dir(gd, sshURL, deployKey), sshURL, sha); err != nil {
 		return err
 	}
 
@@ -105,21 +127,35 @@ func ActionMergexp(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 
-	for _, mr := range mrs {
-		if err := FetchMergeRequest(ctx, gc, gdFetch, mr); err != nil {
-			return fmt.Errorf("fetching merge request %d %s failed: %w", mr.ID, mr.Title, err)
-		}
+	if err := fetchMergeRequestSources(ctx, projectURLs, gd, ids, deployKey, mrs, cmd.Int(flags.FetchWorkers)); err != nil {
+		return err
 	}
 
 	// merging the merging requests
-	var mergeRefs []merger.MergeRef
-	for _, mr := range mrs {
-		mergeRefs = append(mergeRefs, mr.MergeRef())
-	}
+	if cmd.Bool(flags.DropConflicting) {
+		var conflicting []skippedMergeRequest
+		mrs, conflicting, err = mergeDroppingConflicts(gd, mrs)
+		if err != nil {
+			return fmt.Errorf("merge branches: %w", err)
+		}
+		skipped = append(skipped, conflicting...)
+
+		// report of conflicts is the last thing logged
+		defer func() {
+			for _, mr := range conflicting {
+				slog.Warn("merge request dropped from experimental", "id", mr.ID, "title", mr.Title, "reason", mr.Reason)
+			}
+		}()
+	} else {
+		var mergeRefs []merger.MergeRef
+		for _, mr := range mrs {
+			mergeRefs = append(mergeRefs, mr.MergeRef())
+		}
 
-	merger := merger.New(gd)
-	if err := merger.MergeBranches(mergeRefs); err != nil {
-		return fmt.Errorf("merge branches: %w", err)
+		merger := merger.New(gd)
+		if err := merger.MergeBranches(mergeRefs); err != nil {
+			return fmt.Errorf("merge branches: %w", err)
+		}
 	}
 
 	// trying to fetch of the last experimental for creation of final commit
@@ -128,32 +164,73 @@ func ActionMergexp(ctx context.Context, cmd *cli.Command) error {
 		return err
 	}
 	if shaExp != "" {
-		if err := fetchSHA(gd, sshURL, shaExp); err != nil {
+		if err := fetchSHA(ids.dir(gd, sshURL, deployKey), sshURL, shaExp); err != nil {
 			return err
 		}
 	}
 
-	if err := MergexpFinalCommit(ctx, gd, shaExp); err != nil {
+	if err := MergexpFinalCommit(ctx, gd, shaExp, skipped); err != nil {
 		return fmt.Errorf("final commit: %w", err)
 	}
 
 	// branch MUST be force pushed
-	slog.Info("push to GitLab", "url", sshURL)
-	if err := gd.Command("git", "push", "-f", sshURL, Experimental).Run(); err != nil {
-		return fmt.Errorf("push to GitLab failed: %w", err)
+	pushes := []push{
+		{Name: "GitLab", URL: sshURL, Refspec: Experimental, Force: true, SSH: ids.identity(sshURL, "")},
+	}
+	if cmd.Bool(flags.RCTag) {
+		rcTag, err := nextRCTag(ids.dir(gd, sshURL, deployKey), sshURL)
+		if err != nil {
+			return fmt.Errorf("release candidate tag: %w", err)
+		}
+		slog.Info("tagging experimental", "tag", rcTag)
+		if err := gd.Command("git", "tag", "-f", rcTag, Experimental).Run(); err != nil {
+			return err
+		}
+		pushes = append(pushes, push{Name: "GitLab", URL: sshURL, Refspec: "refs/tags/" + rcTag, SSH: ids.identity(sshURL, "")})
+	}
+	var targetPushes []push
+	for _, t := range targets {
+		targetPushes = append(targetPushes, push{Name: t.Name, URL: t.URL, Refspec: Experimental + ":" + t.Branch, Force: true, SSH: ids.identity(t.URL, "")})
 	}
 
-	test1URL := cmd.String(flags.Test1URL)
-	slog.Info("push to TEST1", "url", test1URL)
-	if err := gd.Command("git", "push", "-f", test1URL, Experimental+":"+Demo).Run(); err != nil {
-		return fmt.Errorf("push to TEST1 environment failed: %w", err)
+	if cmd.Bool(flags.DryRun) {
+		slog.Info("dry run, nothing is pushed")
+		return printPushPlan(os.Stdout, gd, append(pushes, targetPushes...))
 	}
 
-	if test2URL := cmd.String(flags.Test2URL); test2URL != "" {
-		slog.Info("push to TEST2", "url", test2URL)
-		if err := gd.Command("git", "push", "-f", test2URL, Experimental+":"+DemoTest2).Run(); err != nil {
-			return fmt.Errorf("push to TEST2 environment failed: %w", err)
+	// GitLab first, test environments are pushed only when experimental is in GitLab
+	timeout := cmd.Duration(flags.PushTimeout)
+	results := runPushGroups(ctx, gd, timeout, [][]push{pushes})
+	if err := pushResultsErr(results); err != nil {
+		printPushSummary(os.Stdout, results)
+		return err
+	}
+	results = append(results, runPushGroups(ctx, gd, timeout, groupPushesByURL(targetPushes))...)
+	printPushSummary(os.Stdout, results)
+
+	experimentalSHA, err := revParse(gd, Experimental)
+	if err != nil {
+		return err
+	}
+	if cmd.Bool(flags.NoteSkipped) {
+		noteSkippedMergeRequests(ctx, gc, mrs, skipped, experimentalSHA)
+	}
+	pushErr := pushResultsErr(results)
+	switch {
+	case !cmd.Bool(flags.SetCommitStatus):
+	case pushErr != nil:
+		// success status would claim the merge requests are in the test environments
+		slog.Warn("commit statuses are not set, experimental was not pushed to all deploy targets")
+	default:
+		targetURL := cmd.String(flags.CommitStatusTargetURL)
+		if targetURL == "" {
+			webURL, err := projectWebURL(ctx, gc, targetProjectID)
+			if err != nil {
+				return err
+			}
+			targetURL = webURL + "/-/commit/" + experimentalSHA
 		}
+		setCommitStatuses(ctx, gc, cmd.String(flags.CommitStatusName), targetURL, mrs, skipped, experimentalSHA)
 	}
-	return nil
+	return pushErr
 }
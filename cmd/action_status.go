@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/mergeexp/git"
+	"github.com/wayan/mergeexp/gitdir"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+// ActionStatus prints SHAs of the branches and the highest version tag on the remotes of the system
+func ActionStatus(ctx context.Context, cmd *cli.Command) error {
+	// only ls-remote is run, no need for work dir
+	gd := &gitdir.Dir{Dir: os.TempDir()}
+
+	targets, err := selectDeployTargets(cmd.StringSlice(flags.DeployTarget), cmd.StringSlice(flags.OnlyDeployTarget))
+	if err != nil {
+		return err
+	}
+	ids, err := sshIdentitiesFromFlag(cmd)
+	if err != nil {
+		return err
+	}
+
+	type remoteRef struct {
+		name, url, ref string
+	}
+	sshURL := cmd.String(flags.TargetProjectSSHURL)
+	refs := []remoteRef{
+		{"GitLab", sshURL, "refs/heads/" + Master},
+		{"GitLab", sshURL, "refs/heads/" + Experimental},
+	}
+	for _, t := range targets {
+		refs = append(refs, remoteRef{t.Name, t.URL, "refs/heads/" + t.Branch})
+	}
+	refs = append(refs, remoteRef{"production", cmd.String(flags.ProductionURL), "refs/heads/" + cmd.String(flags.ProductionBranch)})
+
+	for _, r := range refs {
+		if r.url == "" {
+			continue
+		}
+		sha, err := lsRemoteSHA(ids.dir(gd, r.url, ""), r.url, r.ref)
+		if err != nil {
+			sha = "unknown"
+		} else if sha == "" {
+			sha = "(none)"
+		}
+		fmt.Printf("%s\t%s\t%s\t%s\n", r.name, r.url, r.ref, sha)
+	}
+
+	tag, err := git.HighestVersionTag(ids.dir(gd, sshURL, ""), sshURL)
+	if err != nil {
+		return err
+	}
+	if tag == nil {
+		fmt.Printf("GitLab\t%s\thighest version tag\t(none)\n", sshURL)
+	} else {
+		fmt.Printf("GitLab\t%s\thighest version tag\t%s %s\n", sshURL, tag.String(), tag.SHA)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/wayan/mergeexp/gitdir"
+)
+
+// changelogEntry is a merge commit included in the release
+type changelogEntry struct {
+	Hash    string
+	Subject string
+	// MergeRequest is the reference like b2btmcz/gts-ocp!35, empty for merges without merge request
+	MergeRequest string
+	URL          string
+}
+
+// text is the line in the release commit message
+func (e changelogEntry) text() string {
+	if e.MergeRequest == "" {
+		// without merge request
+		return fmt.Sprintf("* %s %s", e.Hash, e.Subject)
+	}
+	//		* 28e6b06f4 OMCTR-14357: HOTFIX - zakládání GP do PK - xml set [b2btmcz/gts-ocp!35] https://gitlab.services.itc.st.sk/b2btmcz/gts-ocp/-/merge_requests/35
+	return fmt.Sprintf("* %s %s [%s] %s", e.Hash, e.Subject, e.MergeRequest, e.URL)
+}
+
+// markdown is the line in the GitLab release notes
+func (e changelogEntry) markdown() string {
+	if e.MergeRequest == "" {
+		return fmt.Sprintf("* %s %s", e.Hash, e.Subject)
+	}
+	return fmt.Sprintf("* %s %s [%s](%s)", e.Hash, e.Subject, e.MergeRequest, e.URL)
+}
+
+// changelogFormat is the system specific part of the changelog
+type changelogFormat struct {
+	// GitLabURL is the base of links to merge requests, like https://gitlab.services.itc.st.sk
+	GitLabURL string
+	// IssueKeyPrefixes are the prefixes of issue keys, like OMCTR-,
+	// the line of merge commit starting with issue key is used as subject
+	IssueKeyPrefixes []string
+}
+
+// deployHotfixChangelog returns the merges between previous and new release
+func deployHotfixChangelog(gd *gitdir.Dir, format changelogFormat, newRelease, prevRelease string) ([]changelogEntry, error) {
+	cmd := gd.Command("git", "log", "--merges", `--pretty=format:%x00%h%x00%s%x00%B%x00`, prevRelease+".."+newRelease)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+	var changelog []changelogEntry
+	for parts := strings.Split(string(out), "\x00"); len(parts) >= 4; parts = parts[4:] {
+		// index 0 is ignored it is either empty string or new line
+		changelog = append(changelog, format.parseEntry(parts[1], parts[2], parts[3]))
+	}
+	return changelog, nil
+}
+
+func (f changelogFormat) parseEntry(hash, subject, body string) changelogEntry {
+	// if there is line starting with issue key (OMCTR-) we use it as subject
+	if len(f.IssueKeyPrefixes) > 0 {
+		var prefixes []string
+		for _, prefix := range f.IssueKeyPrefixes {
+			prefixes = append(prefixes, regexp.QuoteMeta(prefix))
+		}
+		re := regexp.MustCompile(`(?m)^((?:` + strings.Join(prefixes, "|") + `).*)$`)
+		if matches := re.FindStringSubmatch(body); matches != nil {
+			subject = matches[1]
+		}
+	}
+	entry := changelogEntry{Hash: hash, Subject: subject}
+
+	if matches := regexp.MustCompile(`See merge request ((\w+/.*?)!(\d+))`).FindStringSubmatch(body); matches != nil {
+		mr := matches[1]
+		part := matches[2]
+		mrid := matches[3]
+		entry.MergeRequest = mr
+		entry.URL = fmt.Sprintf("%s/%s/-/merge_requests/%s", strings.TrimSuffix(f.GitLabURL, "/"), part, mrid)
+	}
+	return entry
+}
+
+// gitLabURL derives GitLab web URL from REST API URL (https://gitlab.services.itc.st.sk/api/v4/)
+func gitLabURL(apiURL string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(apiURL, "/"), "/api/v4")
+}
+
+// deployHotfixReleaseMessage is the message of new empty commit with changes from previous release
+func deployHotfixReleaseMessage(newTag string, changelog []changelogEntry) string {
+	var result = fmt.Sprintf("Release %s\n\nChangelog:\n", newTag)
+	for _, entry := range changelog {
+		result += entry.text() + "\n"
+	}
+	return result
+}
+
+// deployHotfixReleaseNotes renders the changelog as markdown for GitLab release
+func deployHotfixReleaseNotes(changelog []changelogEntry) string {
+	var result = "## Changelog\n\n"
+	for _, entry := range changelog {
+		result += entry.markdown() + "\n"
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+const (
+	// MultiCommandName is the name of multi-command binary
+	MultiCommandName = "oc-mergexp-gl"
+	// SystemEnvVar is environment variable with the system of multi-command binary
+	SystemEnvVar = "OC_MERGEXP_SYSTEM"
+)
+
+// Cli returns the multi-command binary for the system
+// with mergexp build, deployhotfix run and status subcommands
+func (s System) Cli() (*cli.Command, error) {
+	mergexp, err := s.mergexpCommand()
+	if err != nil {
+		return nil, err
+	}
+	deployHotfix, err := s.deployHotfixCommand()
+	if err != nil {
+		return nil, err
+	}
+	status, err := s.statusCommand()
+	if err != nil {
+		return nil, err
+	}
+
+	return &cli.Command{
+		Version: Version,
+		Name:    MultiCommandName,
+		Usage:   "experimental branches and hotfix deployments of systems from GitLab",
+		Flags: []cli.Flag{
+			// the value is already used when the command is built, see systemFromArgs
+			&cli.StringFlag{
+				Name:    flags.System,
+				Usage:   "The system (ocp, cow or defined in configuration file) the commands work with",
+				Value:   string(s),
+				Sources: cli.EnvVars(SystemEnvVar),
+			},
+		},
+		Commands: []*cli.Command{mergexp, deployHotfix, status},
+	}, nil
+}
+
+// Main runs the command according to the name of the binary,
+// old binary names like ocp-mergexp-gl or cow-deployhotfix are aliases of the standalone commands
+func Main() {
+	name := filepath.Base(os.Args[0])
+	if name == MultiCommandName {
+		Run(systemFromArgs(os.Args[1:]).Cli())
+		return
+	}
+	if matches := regexp.MustCompile(`^(.+)-(mergexp|deployhotfix)(-gl)?$`).FindStringSubmatch(name); matches != nil {
+		s := System(matches[1])
+		if matches[2] == "mergexp" {
+			Run(s.CliMergexp())
+		} else {
+			Run(s.CliDeployHotfix())
+		}
+		return
+	}
+	Run(systemFromArgs(os.Args[1:]).Cli())
+}
+
+// systemFromArgs finds the system in command line arguments before they are parsed,
+// the flags of the subcommands and their defaults depend on it
+func systemFromArgs(args []string) System {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			// not a flag
+			continue
+		}
+		if value, found := strings.CutPrefix(name, flags.System+"="); found {
+			return System(value)
+		}
+		if name == flags.System && i+1 < len(args) {
+			return System(args[i+1])
+		}
+	}
+	if value := os.Getenv(SystemEnvVar); value != "" {
+		return System(value)
+	}
+	return OCP
+}
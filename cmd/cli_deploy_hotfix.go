@@ -1,67 +1,168 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/urfave/cli/v3"
 	"github.com/wayan/oc-mergexp-gl/cmd/flags"
 )
 
+// CliDeployHotfix returns standalone deploy-hotfix command of the system (ocp-deployhotfix)
 func (s System) CliDeployHotfix() (*cli.Command, error) {
-	homeDir, err := os.UserHomeDir()
+	flgs, err := s.deployHotfixFlags()
+	if err != nil {
+		return nil, err
+	}
+	maintenance, err := s.maintenanceCommand("deployhotfix")
+	if err != nil {
+		return nil, err
+	}
+
+	return &cli.Command{
+		Version: Version,
+		Name:    string(s) + "-deployhotfix-gl",
+		Usage:   deployHotfixUsage,
+		Flags:   flgs,
+		Action:  ActionDeployHotfix,
+		// maintenance of the work dir, e.g. ocp-deployhotfix-gl maintenance
+		Commands: []*cli.Command{maintenance},
+	}, nil
+}
+
+// deployHotfixCommand returns deployhotfix command of multi-command binary with run subcommand
+func (s System) deployHotfixCommand() (*cli.Command, error) {
+	flgs, err := s.deployHotfixFlags()
+	if err != nil {
+		return nil, err
+	}
+	maintenance, err := s.maintenanceCommand("deployhotfix")
+	if err != nil {
+		return nil, err
+	}
+
+	return &cli.Command{
+		Name:  "deployhotfix",
+		Usage: "release of master to production",
+		Commands: []*cli.Command{
+			{
+				Name:   "run",
+				Usage:  deployHotfixUsage,
+				Flags:  flgs,
+				Action: ActionDeployHotfix,
+			},
+			maintenance,
+		},
+	}, nil
+}
+
+const deployHotfixUsage = "bumping the tag and pushing the master branch from GitLab to production"
+
+func (s System) deployHotfixFlags() ([]cli.Flag, error) {
+	workdir, err := s.workdir("deployhotfix")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.Config()
 	if err != nil {
-		return nil, fmt.Errorf("error getting home directory: %v", err)
+		return nil, err
 	}
 
 	// building flags
-	varPrefix := ocpCowValue(s, "OCP_DEPLOYHOTFIX_", "COW_DEPLOYHOTFIX_")
-	serviceName := ocpCowValue(s, "ocp-deployhotfix-gl", "cow-deployhotfix-gl")
+	varPrefix := s.envPrefix("DEPLOYHOTFIX")
 	flgs := []cli.Flag{
 		&cli.StringFlag{
 			Name:    flags.Workdir,
 			Usage:   "The directory with git repo where the actions are run",
-			Value:   homeDir + ocpCowValue(s, "/.ocp-deployhotfix", "/.cow-deployhotfix"),
+			Value:   workdir,
 			Sources: cli.EnvVars(varPrefix + "DIR"),
 		},
 		&cli.StringFlag{
 			Name:    flags.TargetProjectSSHURL,
 			Usage:   "GitLab REST API URL",
-			Value:   ocpCowValue(s, OCPTargetProjectSSHURL, CowTargetProjectSSHURL),
+			Value:   cfg.TargetProjectSSHURL,
 			Sources: cli.EnvVars(varPrefix + "GITLAB_SSHURL"),
 		},
+		&cli.IntFlag{
+			Name:    flags.TargetProjectID,
+			Usage:   "The id of the main GitLab project",
+			Value:   cfg.TargetProjectID,
+			Sources: cli.EnvVars(varPrefix + "PROJECT_ID"),
+		},
+		&cli.StringFlag{
+			Name:    flags.GitLabAPIURL,
+			Usage:   "GitLab REST API URL",
+			Value:   cfg.GitLabAPIURL,
+			Sources: cli.EnvVars(varPrefix + "GITLAB_API_URL"),
+		},
+		&cli.StringFlag{
+			Name:    flags.GitLabURL,
+			Usage:   "GitLab URL used in links to merge requests in changelog, derived from GitLab REST API URL if not set",
+			Value:   cfg.GitLabURL,
+			Sources: cli.EnvVars(varPrefix + "GITLAB_URL"),
+		},
+		&cli.StringSliceFlag{
+			Name:    flags.IssueKeyPrefix,
+			Usage:   "Prefix of issue keys, the line of merge commit starting with the issue key is used in changelog",
+			Value:   cfg.IssueKeyPrefixes,
+			Sources: cli.EnvVars(varPrefix + "ISSUE_KEY_PREFIX"),
+		},
 		&cli.StringFlag{
 			Name:    flags.ProductionURL,
 			Usage:   "SSH URL to production environment",
-			Value:   ocpCowValue(s, OCPProdURL, CowProdURL),
+			Value:   cfg.ProductionURL,
 			Sources: cli.EnvVars(varPrefix + "PRODUCTION_URL"),
 		},
 		&cli.StringFlag{
 			Name:    flags.ProductionBranch,
 			Usage:   "branch on remote repo to push to",
-			Value:   ocpCowValue(s, OCPProductionBranch, CowProductionBranch),
+			Value:   cfg.ProductionBranch,
 			Sources: cli.EnvVars(varPrefix + "PRODUCTION_BRANCH"),
 		},
+		&cli.StringFlag{
+			Name:    flags.DeployKey,
+			Usage:   "Path to deploy key for GitLab, ssh uses its default identities if not set",
+			Sources: cli.EnvVars(varPrefix + "DEPLOY_KEY"),
+		},
+		&cli.StringFlag{
+			Name:    flags.ProductionDeployKey,
+			Usage:   "Path to deploy key for production, ssh uses its default identities if not set",
+			Sources: cli.EnvVars(varPrefix + "PRODUCTION_DEPLOY_KEY"),
+		},
+		sshIdentityFlag(varPrefix, cfg.SSHIdentities),
+		&cli.StringFlag{
+			Name:  flags.DevelopBranch,
+			Usage: "into this branch we merge the second parent of master, no harmonization if empty",
+			Value: cfg.DevelopBranch,
+		},
+		&cli.StringFlag{
+			Name:  flags.Bump,
+			Usage: "Part of the highest version tag increased for the new release: patch, minor or major",
+			Value: BumpPatch,
+		},
+		&cli.StringFlag{
+			Name:  flags.ReleaseVersion,
+			Usage: "Explicit version of the new release (MAJOR.MINOR.PATCH), must be greater than the highest version tag",
+		},
+		&cli.DurationFlag{
+			Name:    flags.PushTimeout,
+			Usage:   "Timeout of a single git push, pushes to different remotes run concurrently",
+			Value:   DefaultPushTimeout,
+			Sources: cli.EnvVars(varPrefix + "PUSH_TIMEOUT"),
+		},
+		&cli.BoolFlag{
+			Name:  flags.Wait,
+			Usage: "Wait until the work dir is not locked by other run instead of failing",
+		},
+		&cli.BoolFlag{
+			Name:  flags.NoAutoRecover,
+			Usage: "Fail when the work dir is left in unfinished merge, rebase or cherry-pick instead of aborting it",
+		},
+		&cli.BoolFlag{
+			Name:  flags.DryRun,
+			Usage: "Prepare the release locally, print the next tag, the release message and the planned pushes and push nothing",
+		},
 	}
+	flgs = append(flgs, privateTokenFlags(varPrefix, "Private token to access GitLab REST API, GitLab release is created only when set")...)
+	flgs = append(flgs, gitLabClientFlags(varPrefix)...)
 
-	if s == OCP {
-		flgs = append(
-			flgs,
-			&cli.StringFlag{
-				Name:  flags.DevelopBranch,
-				Usage: "into this branch we merge the second parent of master",
-				Value: Develop,
-			},
-		)
-	}
-
-	return &cli.Command{
-		Version:        Version,
-		Name:           serviceName,
-		Usage:          "bumping the tag and pushing the master branch from GitLab to production",
-		Flags:          flgs,
-		DefaultCommand: "build",
-		Action:         ActionDeployHotfix,
-	}, nil
-
+	return flgs, nil
 }
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+// maintenanceCommand returns maintenance subcommand for the work dir of the command (mergexp, deployhotfix)
+func (s System) maintenanceCommand(command string) (*cli.Command, error) {
+	workdir, err := s.workdir(command)
+	if err != nil {
+		return nil, err
+	}
+
+	varPrefix := s.envPrefix(command)
+	return &cli.Command{
+		Name:  "maintenance",
+		Usage: "resetting unfinished merges, removing temporary branches and tags and collecting garbage in the work dir",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    flags.Workdir,
+				Usage:   "The directory with git repo to maintain",
+				Value:   workdir,
+				Sources: cli.EnvVars(varPrefix + "DIR"),
+			},
+			&cli.BoolFlag{
+				Name:  flags.Wait,
+				Usage: "Wait until the work dir is not locked by other run instead of failing",
+			},
+		},
+		Action: ActionMaintenance,
+	}, nil
+}
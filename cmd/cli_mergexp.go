@@ -1,88 +1,195 @@
 package cmd
 
 import (
-	"fmt"
-	"os"
-
 	"github.com/urfave/cli/v3"
 	"github.com/wayan/oc-mergexp-gl/cmd/flags"
 )
 
-// CliMergeexp returns
+// CliMergexp returns standalone mergexp command of the system (ocp-mergexp-gl)
 func (s System) CliMergexp() (*cli.Command, error) {
-	homeDir, err := os.UserHomeDir()
+	flgs, err := s.mergexpFlags()
+	if err != nil {
+		return nil, err
+	}
+	maintenance, err := s.maintenanceCommand("mergexp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &cli.Command{
+		Version: Version,
+		Name:    string(s) + "-mergexp-gl",
+		Usage:   mergexpUsage,
+		Flags:   flgs,
+		Action:  ActionMergexp,
+		// maintenance of the work dir, e.g. ocp-mergexp-gl maintenance
+		Commands: []*cli.Command{maintenance},
+	}, nil
+}
+
+// mergexpCommand returns mergexp command of multi-command binary with build subcommand
+func (s System) mergexpCommand() (*cli.Command, error) {
+	flgs, err := s.mergexpFlags()
 	if err != nil {
-		return nil, fmt.Errorf("error getting home directory: %v", err)
+		return nil, err
+	}
+	maintenance, err := s.maintenanceCommand("mergexp")
+	if err != nil {
+		return nil, err
+	}
+
+	return &cli.Command{
+		Name:  "mergexp",
+		Usage: "experimental branch",
+		Commands: []*cli.Command{
+			{
+				Name:   "build",
+				Usage:  mergexpUsage,
+				Flags:  flgs,
+				Action: ActionMergexp,
+			},
+			maintenance,
+		},
+	}, nil
+}
+
+const mergexpUsage = "building and deploying experimental branch from GitLab merge requests"
+
+func (s System) mergexpFlags() ([]cli.Flag, error) {
+	workdir, err := s.workdir("mergexp")
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := s.Config()
+	if err != nil {
+		return nil, err
 	}
 
 	// building flags
-	varPrefix := ocpCowValue(s, "OCP_MERGEXP_", "COW_MERGEXP_")
-	serviceName := ocpCowValue(s, "ocp-mergexp-gl", "cow-mergexp-gl")
+	varPrefix := s.envPrefix("MERGEXP")
 	flgs := []cli.Flag{
 		&cli.StringFlag{
 			Name:    flags.Workdir,
 			Usage:   "The directory with git repo where the branch is built.",
-			Value:   homeDir + ocpCowValue(s, "/.ocp-mergexp", "/.cow-mergexp"),
+			Value:   workdir,
 			Sources: cli.EnvVars(varPrefix + "DIR"),
 		},
-		&cli.StringFlag{
-			Name:     flags.PrivateToken,
-			Usage:    "Private token to access GitLab REST API",
-			Sources:  cli.EnvVars(varPrefix + "PRIVATE_TOKEN"),
-			Required: true,
-		},
 		&cli.IntFlag{
 			Name:    flags.TargetProjectID,
 			Usage:   "The id of the main GitLab project",
-			Value:   ocpCowValue(s, OCPTargetProjectID, CowTargetProjectID),
+			Value:   cfg.TargetProjectID,
 			Sources: cli.EnvVars(varPrefix + "PROJECT_ID"),
 		},
 		&cli.StringFlag{
 			Name:    flags.GitLabAPIURL,
 			Usage:   "GitLab REST API URL",
-			Value:   GitLabAPIURL,
+			Value:   cfg.GitLabAPIURL,
 			Sources: cli.EnvVars(varPrefix + "GITLAB_API_URL"),
 		},
 		&cli.StringFlag{
 			Name:  flags.StartBranch,
 			Usage: "branch to start the building",
-			Value: ocpCowValue(s, "develop", "master"),
+			Value: cfg.StartBranch,
 		},
 		&cli.IntSliceFlag{
 			Name:    flags.SkipMergeRequests,
 			Aliases: []string{"s"},
 			Usage:   "Id of merge requests to be skipped from building the branch",
 		},
+		&cli.StringSliceFlag{
+			Name:    flags.IncludeLabels,
+			Usage:   "Only merge requests with at least one of these labels are merged",
+			Sources: cli.EnvVars(varPrefix + "INCLUDE_LABELS"),
+		},
+		&cli.StringSliceFlag{
+			Name:    flags.ExcludeLabels,
+			Usage:   "Merge requests with any of these labels are never merged",
+			Sources: cli.EnvVars(varPrefix + "EXCLUDE_LABELS"),
+		},
+		&cli.BoolFlag{
+			Name:    flags.IncludeDrafts,
+			Usage:   "Merge also draft merge requests, which are skipped by default",
+			Sources: cli.EnvVars(varPrefix + "INCLUDE_DRAFTS"),
+		},
+		&cli.BoolFlag{
+			Name:    flags.RequirePipelineSuccess,
+			Usage:   "Merge only merge requests whose head pipeline succeeded",
+			Sources: cli.EnvVars(varPrefix + "REQUIRE_PIPELINE_SUCCESS"),
+		},
+		&cli.BoolFlag{
+			Name:    flags.DropConflicting,
+			Usage:   "Merge requests conflicting with the others are left out instead of asking for conflict resolution",
+			Sources: cli.EnvVars(varPrefix + "DROP_CONFLICTING"),
+		},
+		&cli.BoolFlag{
+			Name:    flags.NoteSkipped,
+			Usage:   "Post a note on every skipped merge request explaining why it is not in the experimental branch",
+			Sources: cli.EnvVars(varPrefix + "NOTE_SKIPPED"),
+		},
+		&cli.BoolFlag{
+			Name:    flags.SetCommitStatus,
+			Usage:   "Set commit status on head of every merge request telling whether it is included in the experimental branch",
+			Sources: cli.EnvVars(varPrefix + "SET_COMMIT_STATUS"),
+		},
+		&cli.StringFlag{
+			Name:  flags.CommitStatusName,
+			Usage: "Name (context) of the commit status",
+			Value: "mergexp/" + Experimental,
+		},
+		&cli.StringFlag{
+			Name:  flags.CommitStatusTargetURL,
+			Usage: "Target URL of the commit status, the experimental commit in GitLab if not set",
+		},
 		&cli.StringFlag{
 			Name:    flags.DeployKey,
 			Usage:   "Path to deploy key for GitLab",
-			Value:   findDefaultDeployKey(),
+			Value:   findDefaultDeployKey(cfg.DeployKeyName),
 			Sources: cli.EnvVars(varPrefix + "DEPLOY_KEY"),
 		},
-		&cli.StringFlag{
-			Name:  flags.Test1URL,
-			Usage: "URL of TEST1 environment",
-			Value: ocpCowValue(s, OCPTest1URL, CowTest1URL),
+		sshIdentityFlag(varPrefix, cfg.SSHIdentities),
+		&cli.StringSliceFlag{
+			Name:    flags.DeployTarget,
+			Usage:   "Test environment as name=url:branch, the experimental branch is pushed to every one",
+			Value:   deployTargetStrings(cfg.DeployTargets),
+			Sources: cli.EnvVars(varPrefix + "DEPLOY_TARGETS"),
+		},
+		&cli.StringSliceFlag{
+			Name:  flags.OnlyDeployTarget,
+			Usage: "Name of deploy target to push to, all deploy targets if not set",
+		},
+		&cli.BoolFlag{
+			Name:    flags.RCTag,
+			Usage:   "Tag the experimental build with release candidate of the next patch version (e.g. 1.4.3-rc.7) and push the tag to GitLab",
+			Sources: cli.EnvVars(varPrefix + "RC_TAG"),
+		},
+		&cli.IntFlag{
+			Name:    flags.FetchWorkers,
+			Usage:   "Number of concurrent fetches of merge request sources",
+			Value:   4,
+			Sources: cli.EnvVars(varPrefix + "FETCH_WORKERS"),
+		},
+		&cli.DurationFlag{
+			Name:    flags.PushTimeout,
+			Usage:   "Timeout of a single git push, pushes to different remotes run concurrently",
+			Value:   DefaultPushTimeout,
+			Sources: cli.EnvVars(varPrefix + "PUSH_TIMEOUT"),
+		},
+		&cli.BoolFlag{
+			Name:  flags.Wait,
+			Usage: "Wait until the work dir is not locked by other run instead of failing",
+		},
+		&cli.BoolFlag{
+			Name:  flags.NoAutoRecover,
+			Usage: "Fail when the work dir is left in unfinished merge, rebase or cherry-pick instead of aborting it",
+		},
+		&cli.BoolFlag{
+			Name:  flags.DryRun,
+			Usage: "Build the experimental branch locally, print the planned pushes and push nothing",
 		},
 	}
+	flgs = append(flgs, privateTokenFlags(varPrefix, "Private token to access GitLab REST API, required")...)
+	flgs = append(flgs, gitLabClientFlags(varPrefix)...)
 
-	if s == OCP {
-		flgs = append(flgs,
-			&cli.StringFlag{
-				Name:  flags.Test2URL,
-				Usage: "URL of TEST2 environment",
-				Value: OCPTest2URL,
-			},
-		)
-	}
-
-	return &cli.Command{
-		Version:        Version,
-		Name:           serviceName,
-		Usage:          "building and deploying experimental branch from GitLabl merge requests",
-		Flags:          flgs,
-		DefaultCommand: "build",
-		Action:         ActionMergexp,
-	}, nil
-
+	return flgs, nil
 }
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+// statusCommand returns status command of multi-command binary
+func (s System) statusCommand() (*cli.Command, error) {
+	cfg, err := s.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	varPrefix := s.envPrefix("STATUS")
+	return &cli.Command{
+		Name:  "status",
+		Usage: "showing branches and tags of the system on GitLab, test and production environments",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:    flags.TargetProjectSSHURL,
+				Usage:   "SSH URL of the main GitLab project",
+				Value:   cfg.TargetProjectSSHURL,
+				Sources: cli.EnvVars(varPrefix + "GITLAB_SSHURL"),
+			},
+			&cli.StringSliceFlag{
+				Name:    flags.DeployTarget,
+				Usage:   "Test environment as name=url:branch, the SHA of its branch is shown",
+				Value:   deployTargetStrings(cfg.DeployTargets),
+				Sources: cli.EnvVars(varPrefix + "DEPLOY_TARGETS"),
+			},
+			&cli.StringSliceFlag{
+				Name:  flags.OnlyDeployTarget,
+				Usage: "Name of deploy target to show, all deploy targets if not set",
+			},
+			&cli.StringFlag{
+				Name:    flags.ProductionURL,
+				Usage:   "SSH URL to production environment",
+				Value:   cfg.ProductionURL,
+				Sources: cli.EnvVars(varPrefix + "PRODUCTION_URL"),
+			},
+			&cli.StringFlag{
+				Name:  flags.ProductionBranch,
+				Usage: "branch on production environment",
+				Value: cfg.ProductionBranch,
+			},
+			sshIdentityFlag(varPrefix, cfg.SSHIdentities),
+		},
+		Action: ActionStatus,
+	}, nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"log/slog"
+
+	"github.com/wayan/mergeexp/gitlab"
+)
+
+// commitStatus is the status of merge request head commit telling whether it is in experimental
+type commitStatus struct {
+	State       string `json:"state"`
+	Name        string `json:"name"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description"`
+}
+
+// setCommitStatuses marks merged merge requests as included in experimental and the skipped ones as skipped,
+// failures are only logged, the branch is already pushed
+func setCommitStatuses(ctx context.Context, gc *gitlab.Client, name, targetURL string, merged []mergeRequest, skipped []skippedMergeRequest, experimentalSHA string) {
+	for _, mr := range merged {
+		status := commitStatus{
+			State:       "success",
+			Name:        name,
+			TargetURL:   targetURL,
+			Description: fmt.Sprintf("included in %s %s", Experimental, experimentalSHA[:8]),
+		}
+		if err := setCommitStatus(ctx, gc, mr.SourceProjectId, mr.Sha, status); err != nil {
+			slog.Warn("setting commit status failed", "id", mr.ID, "title", mr.Title, "error", err)
+		}
+	}
+	for _, mr := range skipped {
+		status := commitStatus{
+			State:       "skipped",
+			Name:        name,
+			TargetURL:   targetURL,
+			Description: truncate(fmt.Sprintf("not in %s %s: %s", Experimental, experimentalSHA[:8], mr.Reason), 255),
+		}
+		if err := setCommitStatus(ctx, gc, mr.SourceProjectId, mr.Sha, status); err != nil {
+			slog.Warn("setting commit status failed", "id", mr.ID, "title", mr.Title, "error", err)
+		}
+	}
+}
+
+func setCommitStatus(ctx context.Context, gc *gitlab.Client, projectID int, sha string, status commitStatus) error {
+	res, err := gc.Req(ctx).
+		SetBody(status).
+		Post(fmt.Sprintf("projects/%d/statuses/%s", projectID, sha))
+	if err != nil {
+		return fmt.Errorf("gitlab call failed: %w", err)
+	}
+	if !res.IsSuccess() {
+		return fmt.Errorf("gitlab call returned: %d", res.StatusCode())
+	}
+	return nil
+}
+
+// projectWebURL returns URL of GitLab project web page
+func projectWebURL(ctx context.Context, gc *gitlab.Client, projectID int) (string, error) {
+	var project struct {
+		WebURL string `json:"web_url"`
+	}
+	res, err := gc.Req(ctx).
+		SetResult(&project).
+		Get(fmt.Sprintf("projects/%d", projectID))
+	if err != nil {
+		return "", fmt.Errorf("gitlab call failed: %w", err)
+	}
+	if !res.IsSuccess() {
+		if res.StatusCode() == http.StatusNotFound {
+			return "", gitlab.ProjectNotFound
+		}
+		return "", fmt.Errorf("gitlab call returned: %d", res.StatusCode())
+	}
+	return project.WebURL, nil
+}
+
+// truncate shortens the string to n characters at most, the cut is marked by ...
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
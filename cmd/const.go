@@ -1,5 +1,7 @@
 package cmd
 
+import "time"
+
 const (
 	OCPTargetProjectID     = 6025
 	OCPTargetProjectSSHURL = "git@gitlab.services.itc.st.sk:b2btmcz/gts-ocp.git"
@@ -27,3 +29,15 @@ const (
 	Develop      = "develop"
 	Master       = "master"
 )
+
+// DefaultPushTimeout is the default timeout of a single git push
+const DefaultPushTimeout = 10 * time.Minute
+
+// defaults of GitLab REST API client
+const (
+	DefaultGitLabTimeout   = 30 * time.Second
+	DefaultGitLabRetries   = 3
+	DefaultGitLabRetryWait = time.Second
+	// DefaultGitLabRetryMaxWait is well above the minute GitLab rate limits usually ask to wait
+	DefaultGitLabRetryMaxWait = 5 * time.Minute
+)
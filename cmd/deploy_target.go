@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DeployTarget is a test environment the experimental branch is pushed to
+type DeployTarget struct {
+	Name   string `yaml:"name"`
+	URL    string `yaml:"url"`
+	Branch string `yaml:"branch"`
+}
+
+// String returns the target in the format of deploy-target option: name=url:branch
+func (t DeployTarget) String() string {
+	return t.Name + "=" + t.URL + ":" + t.Branch
+}
+
+// parseDeployTarget parses name=url:branch, the url may contain colons, the branch is after the last one
+func parseDeployTarget(s string) (DeployTarget, error) {
+	name, rest, found := strings.Cut(s, "=")
+	if !found || name == "" {
+		return DeployTarget{}, fmt.Errorf("invalid deploy target %q, expected name=url:branch", s)
+	}
+	idx := strings.LastIndex(rest, ":")
+	if idx <= 0 || idx == len(rest)-1 {
+		return DeployTarget{}, fmt.Errorf("invalid deploy target %q, expected name=url:branch", s)
+	}
+	return DeployTarget{Name: name, URL: rest[:idx], Branch: rest[idx+1:]}, nil
+}
+
+func deployTargetStrings(targets []DeployTarget) []string {
+	var result []string
+	for _, t := range targets {
+		result = append(result, t.String())
+	}
+	return result
+}
+
+// selectDeployTargets parses the deploy targets and keeps only the selected ones, all if there is no selection
+func selectDeployTargets(specs, selected []string) ([]DeployTarget, error) {
+	var targets []DeployTarget
+	for _, spec := range specs {
+		t, err := parseDeployTarget(spec)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if len(selected) == 0 {
+		return targets, nil
+	}
+
+	for _, name := range selected {
+		if !slices.ContainsFunc(targets, func(t DeployTarget) bool { return strings.EqualFold(t.Name, name) }) {
+			return nil, fmt.Errorf("unknown deploy target %q", name)
+		}
+	}
+	return slices.DeleteFunc(targets, func(t DeployTarget) bool {
+		return !slices.ContainsFunc(selected, func(name string) bool { return strings.EqualFold(t.Name, name) })
+	}), nil
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/wayan/mergeexp/gitdir"
+	"github.com/wayan/mergeexp/gitlab"
+)
+
+// projectSSHURLs caches SSH URLs of GitLab projects for the run
+type projectSSHURLs struct {
+	gc   *gitlab.Client
+	mu   sync.Mutex
+	urls map[int]string
+}
+
+func newProjectSSHURLs(gc *gitlab.Client) *projectSSHURLs {
+	return &projectSSHURLs{gc: gc, urls: make(map[int]string)}
+}
+
+func (p *projectSSHURLs) get(ctx context.Context, projectID int) (string, error) {
+	p.mu.Lock()
+	url, ok := p.urls[projectID]
+	p.mu.Unlock()
+	if ok {
+		return url, nil
+	}
+
+	url, err := p.gc.ProjectSSHUrl(ctx, projectID)
+	if err != nil {
+		return "", err
+	}
+	p.mu.Lock()
+	p.urls[projectID] = url
+	p.mu.Unlock()
+	return url, nil
+}
+
+// fetchMergeRequestSources fetches the SHAs of merge requests not present yet,
+// one git fetch per source project, at most workers fetches at once.
+// The ssh identity matching the project URL is used, the deploy key otherwise.
+func fetchMergeRequestSources(ctx context.Context, urls *projectSSHURLs, wd *gitdir.Dir, ids sshIdentities, deployKey string, mrs []mergeRequest, workers int) error {
+	// merge requests to fetch by source project
+	var projects []int
+	missing := make(map[int][]mergeRequest)
+	for _, mr := range mrs {
+		if wd.ShaExists(mr.Sha) {
+			continue
+		}
+		if _, ok := missing[mr.SourceProjectId]; !ok {
+			projects = append(projects, mr.SourceProjectId)
+		}
+		missing[mr.SourceProjectId] = append(missing[mr.SourceProjectId], mr)
+	}
+
+	jobs := make(chan int)
+	errs := make([]error, len(projects))
+	var wg sync.WaitGroup
+	for range max(workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fetchProjectSHAs(ctx, urls, wd, ids, deployKey, projects[i], missing[projects[i]])
+			}
+		}()
+	}
+	for i := range projects {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// fetchProjectSHAs fetches the SHAs of the merge requests from their source project
+func fetchProjectSHAs(ctx context.Context, urls *projectSSHURLs, wd *gitdir.Dir, ids sshIdentities, deployKey string, projectID int, mrs []mergeRequest) error {
+	var shas, names []string
+	for _, mr := range mrs {
+		if !slices.Contains(shas, mr.Sha) {
+			shas = append(shas, mr.Sha)
+		}
+		names = append(names, fmt.Sprintf("%d %s", mr.ID, mr.Title))
+	}
+
+	// trying to fetch the url of repo
+	sshURL, err := urls.get(ctx, projectID)
+	if err == nil {
+		err = fetchSHAs(ids.dir(wd, sshURL, deployKey), sshURL, shas...)
+	}
+	if err != nil {
+		return fmt.Errorf("fetching merge request(s) %s from project %d failed: %w", strings.Join(names, ", "), projectID, err)
+	}
+	return nil
+}
@@ -7,15 +7,30 @@ import (
 )
 
 func fetchSHA(wd *gitdir.Dir, sshURL, sha string) error {
-	if wd.ShaExists(sha) {
+	return fetchSHAs(wd, sshURL, sha)
+}
+
+// fetchSHAs fetches the missing SHAs from the repo in a single git fetch
+func fetchSHAs(wd *gitdir.Dir, sshURL string, shas ...string) error {
+	var missing []string
+	for _, sha := range shas {
+		if !wd.ShaExists(sha) {
+			missing = append(missing, sha)
+		}
+	}
+	if len(missing) == 0 {
 		// already exists
 		return nil
 	}
-	if err := wd.Command("git", "fetch", sshURL, sha).Run(); err != nil {
-		return fmt.Errorf("fetching %q %q: %w", sshURL, sha, err)
+	// FETCH_HEAD is not written, fetches may run concurrently
+	args := append([]string{"fetch", "--no-write-fetch-head", sshURL}, missing...)
+	if err := wd.Command("git", args...).Run(); err != nil {
+		return fmt.Errorf("fetching %q %q: %w", sshURL, missing, err)
 	}
-	if !wd.ShaExists(sha) {
-		return fmt.Errorf("SHA not available after fetch")
+	for _, sha := range missing {
+		if !wd.ShaExists(sha) {
+			return fmt.Errorf("SHA %s not available after fetch", sha)
+		}
 	}
 	return nil
 }
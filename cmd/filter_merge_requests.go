@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"log/slog"
+)
+
+// skippedMergeRequest is a merge request left out of the experimental branch
+type skippedMergeRequest struct {
+	mergeRequest
+	Reason string
+}
+
+// mergeRequestFilter returns the reason why the merge request is skipped, empty string if it is kept
+type mergeRequestFilter func(mr mergeRequest) string
+
+// filterMergeRequests splits merge requests into those kept for the build and the skipped ones,
+// the reason of the first filter rejecting the merge request wins
+func filterMergeRequests(mrs []mergeRequest, filters ...mergeRequestFilter) ([]mergeRequest, []skippedMergeRequest) {
+	var kept []mergeRequest
+	var skipped []skippedMergeRequest
+
+MRS:
+	for _, mr := range mrs {
+		for _, filter := range filters {
+			if reason := filter(mr); reason != "" {
+				slog.Info("skipping merge request", "id", mr.ID, "title", mr.Title, "reason", reason)
+				skipped = append(skipped, skippedMergeRequest{mergeRequest: mr, Reason: reason})
+				continue MRS
+			}
+		}
+		kept = append(kept, mr)
+	}
+	return kept, skipped
+}
+
+// skipIDsFilter skips merge requests by their ids
+func skipIDsFilter(ids []int) mergeRequestFilter {
+	return func(mr mergeRequest) string {
+		if slices.Contains(ids, mr.ID) {
+			return "skipped on request"
+		}
+		return ""
+	}
+}
+
+// includeLabelsFilter keeps only merge requests having at least one of the labels
+func includeLabelsFilter(labels []string) mergeRequestFilter {
+	return func(mr mergeRequest) string {
+		for _, label := range labels {
+			if hasLabel(mr, label) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("has none of the labels %s", strings.Join(labels, ", "))
+	}
+}
+
+// excludeLabelsFilter skips merge requests having any of the labels
+func excludeLabelsFilter(labels []string) mergeRequestFilter {
+	return func(mr mergeRequest) string {
+		for _, label := range labels {
+			if hasLabel(mr, label) {
+				return fmt.Sprintf("labelled %s", label)
+			}
+		}
+		return ""
+	}
+}
+
+// draftFilter skips draft merge requests, either flagged by GitLab or marked by the title prefix
+func draftFilter(mr mergeRequest) string {
+	if mr.Draft || mr.WorkInProgress {
+		return "draft"
+	}
+	title := strings.ToLower(strings.TrimSpace(mr.Title))
+	for _, prefix := range []string{"draft:", "wip:"} {
+		if strings.HasPrefix(title, prefix) {
+			return "draft"
+		}
+	}
+	return ""
+}
+
+// pipelineFilter keeps only merge requests whose head pipeline succeeded
+func pipelineFilter(statuses map[int]string) mergeRequestFilter {
+	return func(mr mergeRequest) string {
+		switch status := statuses[mr.ID]; status {
+		case "success":
+			return ""
+		case "":
+			return "no pipeline for the head commit"
+		default:
+			return "pipeline " + status
+		}
+	}
+}
+
+func hasLabel(mr mergeRequest, label string) bool {
+	return slices.ContainsFunc(mr.Labels, func(l string) bool {
+		return strings.EqualFold(l, label)
+	})
+}
@@ -3,13 +3,13 @@ package cmd
 import "os"
 
 // returns path of default deployment key
-func findDefaultDeployKey() string {
+func findDefaultDeployKey(name string) string {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return ""
 	}
 
-	path := homeDir + "/.ssh/" + OCPDeploymentKeyName
+	path := homeDir + "/.ssh/" + name
 	if _, err := os.Stat(path); err != nil {
 		return ""
 	}
@@ -2,19 +2,73 @@ package flags
 
 // names of flags as constants
 const (
+	System              = "system"
 	Workdir             = "workdir"
 	PrivateToken        = "private-token"
 	TargetProjectID     = "target-project-id"
 	GitLabAPIURL        = "gitlab-api-url"
+	GitLabURL           = "gitlab-url"
+	IssueKeyPrefix      = "issue-key-prefix"
 	StartBranch         = "start-branch"
-	Test1URL            = "test1-url"
-	Test2URL            = "test2-url"
+	DeployTarget        = "deploy-target"
+	OnlyDeployTarget    = "only-deploy-target"
 	DeployKey           = "deploy-key"
 	SkipMergeRequests   = "skip-merge-requests"
+	IncludeLabels       = "include-labels"
+	ExcludeLabels       = "exclude-labels"
+	IncludeDrafts       = "include-drafts"
 	ProductionURL       = "production-url"
 	ProductionBranch    = "production-branch"
 	TargetProjectSSHURL = "target-project-ssh-url"
+	DryRun              = "dry-run"
+	PushTimeout         = "push-timeout"
+	FetchWorkers        = "fetch-workers"
+	Wait                = "wait"
 
 	// branch which will be harmonized by merging master^2
 	DevelopBranch = "develop-branch"
+
+	// version of the new release
+	Bump           = "bump"
+	ReleaseVersion = "release-version"
+
+	// merge requests without successful head pipeline are skipped
+	RequirePipelineSuccess = "require-pipeline-success"
+	// conflicting merge requests are skipped, not resolved
+	DropConflicting = "drop-conflicting"
+	// skipped merge requests get a note why
+	NoteSkipped = "note-skipped"
+
+	// commit status on merge requests heads
+	SetCommitStatus       = "set-commit-status"
+	CommitStatusName      = "commit-status-name"
+	CommitStatusTargetURL = "commit-status-target-url"
+
+	// release candidate tag of experimental build
+	RCTag = "rc-tag"
+
+	// interrupted merge, rebase or cherry-pick in work dir fails the run
+	NoAutoRecover = "no-auto-recover"
+
+	// deploy key for pushes to production, deploy-key is for GitLab
+	ProductionDeployKey = "production-deploy-key"
+
+	// ssh identity for remotes with matching URL
+	SSHIdentity = "ssh-identity"
+
+	// TLS of GitLab REST API
+	CACert             = "ca-cert"
+	ClientCert         = "client-cert"
+	ClientKey          = "client-key"
+	InsecureSkipVerify = "insecure-skip-verify"
+
+	// private token read from file or printed by command
+	PrivateTokenFile    = "private-token-file"
+	PrivateTokenCommand = "private-token-command"
+
+	// timeout and retries of GitLab REST API requests
+	GitLabTimeout      = "gitlab-timeout"
+	GitLabRetries      = "gitlab-retries"
+	GitLabRetryWait    = "gitlab-retry-wait"
+	GitLabRetryMaxWait = "gitlab-retry-max-wait"
 )
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/wayan/mergeexp/gitlab"
+)
+
+// getAllPages fetches all pages of GitLab list endpoint following the X-Next-Page header
+func getAllPages[T any](ctx context.Context, gc *gitlab.Client, path string, query map[string]string) ([]T, error) {
+	var items []T
+
+	for page := "1"; page != ""; {
+		var itemsPage []T
+
+		resp, err := gc.Req(ctx).
+			SetQueryParams(query).
+			SetQueryParam("per_page", "100").
+			SetQueryParam("page", page).
+			SetResult(&itemsPage).
+			Get(path)
+		if err != nil {
+			return nil, fmt.Errorf("gitlab failed: %w", err)
+		}
+		if !resp.IsSuccess() {
+			return nil, fmt.Errorf("fetch for GitLab %s failed with %s status", path, resp.Status())
+		}
+
+		items = append(items, itemsPage...)
+		page = resp.Header().Get("X-Next-Page")
+		if _, err := strconv.Atoi(page); err != nil {
+			// empty or garbage, no more pages
+			page = ""
+		}
+	}
+
+	return items, nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"log/slog"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// transientStatus reports whether the request failed by rate limit or server error and may succeed later
+func transientStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// transientStatusError turns rate limit and server error responses into error naming the endpoint and status,
+// the error of the last attempt is returned when retries run out
+func transientStatusError(_ *resty.Client, resp *resty.Response) error {
+	if !transientStatus(resp.StatusCode()) {
+		return nil
+	}
+	if wait := retryAfterWait(resp); wait > 0 {
+		return fmt.Errorf("gitlab %s %s returned %s asking to retry after %s (attempt %d)",
+			resp.Request.Method, resp.Request.URL, resp.Status(), wait, resp.Request.Attempt)
+	}
+	return fmt.Errorf("gitlab %s %s returned %s (attempt %d)", resp.Request.Method, resp.Request.URL, resp.Status(), resp.Request.Attempt)
+}
+
+// logRetry returns retry hook logging the failed attempt which is going to be retried
+func logRetry(retries int) resty.OnRetryFunc {
+	return func(resp *resty.Response, err error) {
+		if resp == nil || resp.Request.Attempt > retries {
+			// no retry left, the error is returned
+			return
+		}
+		slog.Warn("gitlab call failed, retrying", "error", err)
+	}
+}
+
+// retryTransient returns the retry condition: network errors and transient statuses.
+// POST, PUT and PATCH may have been processed by the server, they are retried only when rate limited.
+// Resty would cut the wait requested by Retry-After to maxWait, such request is not retried at all,
+// its error tells the requested wait.
+func retryTransient(maxWait time.Duration) resty.RetryConditionFunc {
+	return func(resp *resty.Response, err error) bool {
+		if resp == nil {
+			// request was not even built
+			return false
+		}
+		if resp.RawResponse == nil {
+			return err != nil && idempotent(resp.Request.Method)
+		}
+		if retryAfterWait(resp) > maxWait {
+			return false
+		}
+		status := resp.StatusCode()
+		if status == http.StatusTooManyRequests {
+			return true
+		}
+		return transientStatus(status) && idempotent(resp.Request.Method)
+	}
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return false
+	}
+	return true
+}
+
+// retryAfter returns the wait requested by Retry-After header, zero means the exponential backoff is used
+func retryAfter(_ *resty.Client, resp *resty.Response) (time.Duration, error) {
+	return retryAfterWait(resp), nil
+}
+
+// retryAfterWait parses Retry-After header (seconds or HTTP date), zero if there is none
+func retryAfterWait(resp *resty.Response) time.Duration {
+	value := strings.TrimSpace(resp.Header().Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
+
+// restyLogger passes resty messages to slog, the final errors are returned to the caller anyway
+type restyLogger struct{}
+
+func (restyLogger) Errorf(format string, v ...any) {
+	slog.Debug(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (restyLogger) Warnf(format string, v ...any) {
+	// failed attempts are logged by logRetry
+	slog.Debug(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+func (restyLogger) Debugf(format string, v ...any) {
+	slog.Debug(strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wayan/mergeexp/gitlab"
+)
+
+// headPipelineStatus returns the status of the latest pipeline run for the head commit of the merge request,
+// empty string if there is no such pipeline
+func headPipelineStatus(ctx context.Context, gc *gitlab.Client, mr mergeRequest) (string, error) {
+	var detail struct {
+		HeadPipeline *struct {
+			Sha    string `json:"sha"`
+			Status string `json:"status"`
+		} `json:"head_pipeline"`
+	}
+
+	res, err := gc.Req(ctx).
+		SetResult(&detail).
+		Get(fmt.Sprintf("projects/%d/merge_requests/%d", mr.TargetProjectId, mr.IID))
+	if err != nil {
+		return "", fmt.Errorf("gitlab call failed: %w", err)
+	}
+	if !res.IsSuccess() {
+		return "", fmt.Errorf("gitlab call returned: %d", res.StatusCode())
+	}
+
+	if detail.HeadPipeline == nil || detail.HeadPipeline.Sha != mr.Sha {
+		// pipeline for the current head has not been created yet
+		return "", nil
+	}
+	return detail.HeadPipeline.Status, nil
+}
+
+// headPipelineStatuses returns statuses of head pipelines by merge request id
+func headPipelineStatuses(ctx context.Context, gc *gitlab.Client, mrs []mergeRequest) (map[int]string, error) {
+	statuses := make(map[int]string, len(mrs))
+	for _, mr := range mrs {
+		status, err := headPipelineStatus(ctx, gc, mr)
+		if err != nil {
+			return nil, fmt.Errorf("pipeline of merge request %d %s: %w", mr.ID, mr.Title, err)
+		}
+		statuses[mr.ID] = status
+	}
+	return statuses, nil
+}
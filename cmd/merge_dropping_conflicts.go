@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"log/slog"
+
+	"github.com/wayan/mergeexp/gitdir"
+)
+
+// mergeDroppingConflicts merges merge requests one by one like merger.MergeBranches,
+// but instead of asking for conflict resolution the conflicting merge request is reset away and skipped.
+// Returns the merged merge requests and the dropped ones.
+func mergeDroppingConflicts(gd *gitdir.Dir, mrs []mergeRequest) ([]mergeRequest, []skippedMergeRequest, error) {
+	var merged []mergeRequest
+	var dropped []skippedMergeRequest
+
+	for i, mr := range mrs {
+		name := mr.MergeRef().Name()
+		slog.Info(fmt.Sprintf("Merging %d of %d (%s)", i+1, len(mrs), name))
+
+		message := fmt.Sprintf("Experimental merge of %s", name)
+		if err := gd.Command("git", "merge", "--no-ff", "--log", "-m", message, mr.Sha).Run(); err == nil {
+			merged = append(merged, mr)
+			continue
+		}
+
+		out, err := gd.Command("git", "diff", "--name-only", "--diff-filter=U").Output()
+		if err != nil {
+			return nil, nil, fmt.Errorf("listing unmerged files of %s: %w", name, err)
+		}
+		files := strings.Fields(string(out))
+
+		if len(files) == 0 {
+			// conflicts may have been resolved by rerere, then only commit is missing
+			if !mergeInProgress(gd) {
+				return nil, nil, fmt.Errorf("merge of %s failed without conflicts", name)
+			}
+			if err := gd.Command("git", "commit", "-m", message+" with resolved conflict(s) using rerere").Run(); err != nil {
+				return nil, nil, fmt.Errorf("commit of %s: %w", name, err)
+			}
+			merged = append(merged, mr)
+			continue
+		}
+
+		slog.Warn("dropping conflicting merge request", "id", mr.ID, "title", mr.Title, "files", files)
+		if err := gd.Command("git", "merge", "--abort").Run(); err != nil {
+			return nil, nil, fmt.Errorf("aborting merge of %s: %w", name, err)
+		}
+		dropped = append(dropped, skippedMergeRequest{
+			mergeRequest: mr,
+			Reason:       "merge conflict in " + strings.Join(files, ", "),
+		})
+	}
+	return merged, dropped, nil
+}
+
+// mergeInProgress reports whether there is unfinished merge in the working tree
+func mergeInProgress(gd *gitdir.Dir) bool {
+	cmd := gd.Command("git", "rev-parse", "-q", "--verify", "MERGE_HEAD")
+	cmd.Stdout = nil
+	cmd.Stderr = nil
+	return cmd.Run() == nil
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"log/slog"
+
+	"github.com/wayan/mergeexp/gitlab"
+)
+
+// skippedNoteMarker identifies the note posted by this tool, so it is updated on the next run instead of duplicated
+const skippedNoteMarker = "<!-- oc-mergexp-gl:experimental -->"
+
+type mergeRequestNote struct {
+	ID     int    `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+}
+
+// noteSkippedMergeRequests tells the authors of skipped merge requests why they are not in experimental
+// and removes such notes from merged ones, failures are only logged, the branch is already pushed
+func noteSkippedMergeRequests(ctx context.Context, gc *gitlab.Client, merged []mergeRequest, skipped []skippedMergeRequest, experimentalSHA string) {
+	for _, mr := range skipped {
+		if err := noteSkippedMergeRequest(ctx, gc, mr, experimentalSHA); err != nil {
+			slog.Warn("note on skipped merge request failed", "id", mr.ID, "title", mr.Title, "error", err)
+		}
+	}
+	for _, mr := range merged {
+		if err := removeSkippedNote(ctx, gc, mr); err != nil {
+			slog.Warn("removing note from merged merge request failed", "id", mr.ID, "title", mr.Title, "error", err)
+		}
+	}
+}
+
+func mergeRequestNotesPath(mr mergeRequest) string {
+	return fmt.Sprintf("projects/%d/merge_requests/%d/notes", mr.TargetProjectId, mr.IID)
+}
+
+// findSkippedNote returns the note posted by this tool, nil if there is none
+func findSkippedNote(ctx context.Context, gc *gitlab.Client, notesPath string) (*mergeRequestNote, error) {
+	notes, err := getAllPages[mergeRequestNote](ctx, gc, notesPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, note := range notes {
+		if !note.System && strings.HasPrefix(note.Body, skippedNoteMarker) {
+			return &note, nil
+		}
+	}
+	return nil, nil
+}
+
+// removeSkippedNote deletes the note of merge request which was skipped by previous run and is merged now
+func removeSkippedNote(ctx context.Context, gc *gitlab.Client, mr mergeRequest) error {
+	notesPath := mergeRequestNotesPath(mr)
+	note, err := findSkippedNote(ctx, gc, notesPath)
+	if err != nil || note == nil {
+		return err
+	}
+
+	slog.Info("removing note from merged merge request", "id", mr.ID, "title", mr.Title)
+	res, err := gc.Req(ctx).Delete(fmt.Sprintf("%s/%d", notesPath, note.ID))
+	if err != nil {
+		return fmt.Errorf("gitlab call failed: %w", err)
+	}
+	if !res.IsSuccess() {
+		return fmt.Errorf("gitlab call returned: %d", res.StatusCode())
+	}
+	return nil
+}
+
+// noteSkippedMergeRequest creates or updates the note explaining why the merge request is not in experimental
+func noteSkippedMergeRequest(ctx context.Context, gc *gitlab.Client, mr skippedMergeRequest, experimentalSHA string) error {
+	body := fmt.Sprintf("%s\nThis merge request is not included in the `%s` branch built as %s.\n\nReason: %s\n",
+		skippedNoteMarker, Experimental, experimentalSHA, mr.Reason)
+
+	notesPath := mergeRequestNotesPath(mr.mergeRequest)
+	note, err := findSkippedNote(ctx, gc, notesPath)
+	if err != nil {
+		return err
+	}
+
+	if note != nil {
+		if note.Body == body {
+			return nil
+		}
+		slog.Info("updating note on skipped merge request", "id", mr.ID, "title", mr.Title)
+		res, err := gc.Req(ctx).
+			SetBody(map[string]string{"body": body}).
+			Put(fmt.Sprintf("%s/%d", notesPath, note.ID))
+		if err != nil {
+			return fmt.Errorf("gitlab call failed: %w", err)
+		}
+		if !res.IsSuccess() {
+			return fmt.Errorf("gitlab call returned: %d", res.StatusCode())
+		}
+		return nil
+	}
+
+	slog.Info("posting note on skipped merge request", "id", mr.ID, "title", mr.Title)
+	res, err := gc.Req(ctx).
+		SetBody(map[string]string{"body": body}).
+		Post(notesPath)
+	if err != nil {
+		return fmt.Errorf("gitlab call failed: %w", err)
+	}
+	if !res.IsSuccess() {
+		return fmt.Errorf("gitlab call returned: %d", res.StatusCode())
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/wayan/mergeexp/gitlab"
+)
+
+// mergeRequest is gitlab.MergeRequest extended by the attributes used to select merge requests for the build
+type mergeRequest struct {
+	gitlab.MergeRequest
+	IID            int      `json:"iid"`
+	Labels         []string `json:"labels"`
+	Draft          bool     `json:"draft"`
+	WorkInProgress bool     `json:"work_in_progress"`
+}
+
+// fetchMergeRequests returns opened merge requests of the target project including drafts,
+// it mirrors gitlab.Client.MergeRequests, but returns more attributes of every merge request
+func fetchMergeRequests(ctx context.Context, gc *gitlab.Client, targetProjectID int) ([]mergeRequest, error) {
+	return getAllPages[mergeRequest](ctx, gc,
+		fmt.Sprintf("projects/%d/merge_requests", targetProjectID),
+		map[string]string{"state": "opened"},
+	)
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/wayan/mergeexp/gitdir"
 )
 
-func MergexpFinalCommit(ctx context.Context, wd *gitdir.Dir, shaExp string) error {
+func MergexpFinalCommit(ctx context.Context, wd *gitdir.Dir, shaExp string, skipped []skippedMergeRequest) error {
 	var err error
 	var commitsNotIncluded string
 
@@ -41,6 +41,13 @@ func MergexpFinalCommit(ctx context.Context, wd *gitdir.Dir, shaExp string) erro
 		) +
 		"\n\n" + commitsNotIncluded
 
+	if len(skipped) > 0 {
+		message = message + "\n\nMerge request(s) not included in this merge:\n\n"
+		for _, mr := range skipped {
+			message = message + fmt.Sprintf("MR %d: %s (%s)\n", mr.ID, mr.Title, mr.Reason)
+		}
+	}
+
 	if err := wd.Command("git", "commit", "--allow-empty", "--message", message).Run(); err != nil {
 		return err
 	}
@@ -0,0 +1,9 @@
+package main
+
+import (
+	"github.com/wayan/oc-mergexp-gl/cmd"
+)
+
+func main() {
+	cmd.Main()
+}
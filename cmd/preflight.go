@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"log/slog"
+
+	"github.com/wayan/mergeexp/gitdir"
+)
+
+// interruptedOperation is a git operation which may be left unfinished in the work dir by a killed run
+type interruptedOperation struct {
+	Name string
+	// files or directories (relative to git dir) present while the operation is in progress
+	Markers []string
+	// command aborting the operation
+	Abort []string
+}
+
+var interruptedOperations = []interruptedOperation{
+	{Name: "merge", Markers: []string{"MERGE_HEAD"}, Abort: []string{"merge", "--abort"}},
+	{Name: "rebase", Markers: []string{"rebase-merge", "rebase-apply"}, Abort: []string{"rebase", "--abort"}},
+	{Name: "cherry-pick", Markers: []string{"CHERRY_PICK_HEAD"}, Abort: []string{"cherry-pick", "--abort"}},
+	{Name: "revert", Markers: []string{"REVERT_HEAD"}, Abort: []string{"revert", "--abort"}},
+}
+
+// preflight checks the work dir left by an interrupted run and repairs it when autoRecover is set,
+// so the build starts from clean state
+func preflight(gd *gitdir.Dir, autoRecover bool) error {
+	gitDir, err := gd.Command("git", "rev-parse", "--absolute-git-dir").Output()
+	if err != nil {
+		return fmt.Errorf("locating git dir of %s: %w", gd.Dir, err)
+	}
+
+	for _, op := range interruptedOperations {
+		if !op.inProgress(strings.TrimSpace(string(gitDir))) {
+			continue
+		}
+		if !autoRecover {
+			return fmt.Errorf("unfinished %s in %s, abort it or run without --no-auto-recover", op.Name, gd.Dir)
+		}
+		slog.Warn("aborting unfinished "+op.Name, "dir", gd.Dir)
+		if err := gd.Command("git", op.Abort...).Run(); err != nil {
+			return fmt.Errorf("aborting unfinished %s: %w", op.Name, err)
+		}
+	}
+
+	out, err := gd.Command("git", "status", "--porcelain", "--untracked-files=no").Output()
+	if err != nil {
+		return fmt.Errorf("checking the working tree state: %w", err)
+	}
+	if changes := strings.TrimSpace(string(out)); changes != "" {
+		if !autoRecover {
+			return fmt.Errorf("dirty index or working tree in %s, reset it or run without --no-auto-recover", gd.Dir)
+		}
+		slog.Warn("resetting dirty index and working tree", "dir", gd.Dir, "changes", changes)
+		if err := gd.Command("git", "reset", "--hard", "--quiet").Run(); err != nil {
+			return fmt.Errorf("resetting the working tree: %w", err)
+		}
+	}
+
+	// detached HEAD does no harm, the branches are (re)created from remote SHAs
+	if err := gd.Command("git", "symbolic-ref", "--quiet", "HEAD").Run(); err != nil {
+		slog.Info("HEAD is detached", "dir", gd.Dir)
+	}
+	return nil
+}
+
+func (op interruptedOperation) inProgress(gitDir string) bool {
+	for _, marker := range op.Markers {
+		if _, err := os.Stat(filepath.Join(gitDir, marker)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+// privateTokenFlags returns flags of GitLab private token: the token itself, the file or the command providing it
+func privateTokenFlags(varPrefix, usage string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    flags.PrivateToken,
+			Usage:   usage + ", prefer private-token-file or private-token-command",
+			Sources: cli.EnvVars(varPrefix + "PRIVATE_TOKEN"),
+		},
+		&cli.StringFlag{
+			Name:    flags.PrivateTokenFile,
+			Usage:   "File with private token to access GitLab REST API",
+			Sources: cli.EnvVars(varPrefix + "PRIVATE_TOKEN_FILE"),
+		},
+		&cli.StringFlag{
+			Name:    flags.PrivateTokenCommand,
+			Usage:   "Shell command printing private token to access GitLab REST API, e.g. 'pass show gitlab'",
+			Sources: cli.EnvVars(varPrefix + "PRIVATE_TOKEN_COMMAND"),
+		},
+	}
+}
+
+// resolvePrivateToken returns the private token from one of private-token, private-token-file or private-token-command,
+// empty string if none is set. The token is redacted from the log from now on.
+func resolvePrivateToken(ctx context.Context, cmd *cli.Command) (string, error) {
+	token, file, command := cmd.String(flags.PrivateToken), cmd.String(flags.PrivateTokenFile), cmd.String(flags.PrivateTokenCommand)
+	var set int
+	for _, v := range []string{token, file, command} {
+		if v != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", fmt.Errorf("only one of %s, %s and %s may be set", flags.PrivateToken, flags.PrivateTokenFile, flags.PrivateTokenCommand)
+	}
+
+	switch {
+	case file != "":
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return "", fmt.Errorf("reading private token: %w", err)
+		}
+		token = strings.TrimSpace(string(content))
+		if token == "" {
+			return "", fmt.Errorf("private token file %s is empty", file)
+		}
+	case command != "":
+		c := exec.CommandContext(ctx, "sh", "-c", command)
+		c.Stderr = os.Stderr
+		out, err := c.Output()
+		if err != nil {
+			return "", fmt.Errorf("running private token command: %w", err)
+		}
+		token = strings.TrimSpace(string(out))
+		if token == "" {
+			return "", errors.New("private token command printed no token")
+		}
+	}
+
+	redactSecret(token)
+	return token, nil
+}
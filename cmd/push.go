@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"log/slog"
+
+	"github.com/wayan/mergeexp/gitdir"
+)
+
+// push is a single git push of a refspec to a remote repository
+type push struct {
+	// Name of the remote used in logs and messages (GitLab, TEST1, ...)
+	Name    string
+	URL     string
+	Refspec string
+	Force   bool
+	// SSH is the identity used by ssh, the ssh defaults if nil
+	SSH *SSHIdentity
+}
+
+func (p push) args() []string {
+	args := []string{"push"}
+	if p.Force {
+		args = append(args, "-f")
+	}
+	return append(args, p.URL, p.Refspec)
+}
+
+// refs returns the local (source) and the remote (destination) part of the refspec
+func (p push) refs() (string, string) {
+	src, dst, found := strings.Cut(strings.TrimPrefix(p.Refspec, "+"), ":")
+	if !found {
+		dst = src
+	}
+	return src, dst
+}
+
+// run runs the push, killing it after timeout (no timeout if zero)
+func (p push) run(ctx context.Context, gd *gitdir.Dir, timeout time.Duration) error {
+	slog.Info("push to "+p.Name, "url", p.URL, "refspec", p.Refspec)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// output is collected, concurrent pushes would mix it up
+	var output bytes.Buffer
+	cmd := withSSHIdentity(gd, p.SSH).Command("git", p.args()...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	// ssh started by git may keep the output open after git is killed
+	cmd.WaitDelay = time.Second
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("push to %s failed: %w", p.Name, err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		<-done
+		err = ctx.Err()
+	}
+	if err != nil {
+		slog.Error("push failed", "remote", p.Name, "url", p.URL, "output", strings.TrimSpace(output.String()))
+		return fmt.Errorf("push to %s failed: %w", p.Name, err)
+	}
+	return nil
+}
+
+// pushResult is the outcome of a push
+type pushResult struct {
+	push
+	Err      error
+	Duration time.Duration
+}
+
+var errPushNotRun = errors.New("not run, previous push to the same remote failed")
+
+// groupPushesByURL groups the pushes to the same remote, keeping their order
+func groupPushesByURL(pushes []push) [][]push {
+	var groups [][]push
+	idx := make(map[string]int)
+	for _, p := range pushes {
+		i, ok := idx[p.URL]
+		if !ok {
+			i = len(groups)
+			idx[p.URL] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], p)
+	}
+	return groups
+}
+
+// runPushGroups runs the groups of pushes concurrently, the pushes in a group one after another.
+// After a failure the rest of the group is not run. The results are in the order of the pushes.
+func runPushGroups(ctx context.Context, gd *gitdir.Dir, timeout time.Duration, groups [][]push) []pushResult {
+	results := make([][]pushResult, len(groups))
+	var wg sync.WaitGroup
+	for i, group := range groups {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var failed bool
+			for _, p := range group {
+				if failed {
+					results[i] = append(results[i], pushResult{push: p, Err: errPushNotRun})
+					continue
+				}
+				start := time.Now()
+				err := p.run(ctx, gd, timeout)
+				results[i] = append(results[i], pushResult{push: p, Err: err, Duration: time.Since(start)})
+				failed = err != nil
+			}
+		}()
+	}
+	wg.Wait()
+	return slices.Concat(results...)
+}
+
+// pushResultsErr returns joined errors of failed pushes
+func pushResultsErr(results []pushResult) error {
+	var errs []error
+	for _, r := range results {
+		if r.Err != nil && r.Err != errPushNotRun {
+			errs = append(errs, r.Err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// printPushSummary prints table with result of every push
+func printPushSummary(w io.Writer, results []pushResult) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REMOTE\tURL\tREFSPEC\tRESULT\tDURATION")
+	for _, r := range results {
+		result := "ok"
+		if r.Err != nil {
+			result = r.Err.Error()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.URL, r.Refspec, result, r.Duration.Round(time.Millisecond))
+	}
+	tw.Flush()
+}
+
+// printPushPlan prints the pushes without running them,
+// each with the SHA currently on the remote and the SHA which would replace it
+func printPushPlan(w io.Writer, gd *gitdir.Dir, pushes []push) error {
+	for _, p := range pushes {
+		src, dst := p.refs()
+		newSHA, err := revParse(gd, src)
+		if err != nil {
+			return fmt.Errorf("resolving %q: %w", src, err)
+		}
+		oldSHA, err := lsRemoteSHA(withSSHIdentity(gd, p.SSH), p.URL, dst)
+		if err != nil {
+			// the plan is still useful without the remote state
+			slog.Warn("cannot read remote ref", "remote", p.Name, "ref", dst, "error", err)
+			oldSHA = "unknown"
+		} else if oldSHA == "" {
+			oldSHA = "(none)"
+		}
+		refspec := p.Refspec
+		if p.Force {
+			// forced update written the git way
+			refspec = "+" + refspec
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s -> %s\n", p.Name, p.URL, refspec, oldSHA, newSHA)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wayan/mergeexp/git"
+	"github.com/wayan/mergeexp/gitdir"
+)
+
+// nextRCTag returns the release candidate tag for the next patch version after the highest version tag,
+// e.g. 1.4.3-rc.7 when the highest version tag is 1.4.2 and 1.4.3-rc.6 already exists
+func nextRCTag(gd *gitdir.Dir, url string) (string, error) {
+	highest, err := git.HighestVersionTag(gd, url)
+	if err != nil {
+		return "", err
+	}
+	if highest == nil {
+		return "", errors.New("missing highest tag")
+	}
+	next, err := nextReleaseVersion(*highest, BumpPatch, "")
+	if err != nil {
+		return "", err
+	}
+
+	prefix := next.String() + "-rc."
+	out, err := gd.Command("git", "ls-remote", "--tags", url, "refs/tags/"+prefix+"*").Output()
+	if err != nil {
+		return "", fmt.Errorf("fetching remote failed: %w", err)
+	}
+
+	re := regexp.MustCompile(`^refs/tags/` + regexp.QuoteMeta(prefix) + `(\d+)(\^\{\})?$`)
+	rc := 0
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if matches := re.FindStringSubmatch(fields[1]); matches != nil {
+			if n, _ := strconv.Atoi(matches[1]); n > rc {
+				rc = n
+			}
+		}
+	}
+	return fmt.Sprintf("%s%d", prefix, rc+1), nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"log"
+)
+
+const redacted = "[REDACTED]"
+
+// redactSecret replaces the secret by [REDACTED] in all further log output, including the logged errors.
+// The default slog handler writes through the log package, so its writer is wrapped.
+func redactSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	log.SetOutput(&redactingWriter{w: log.Writer(), secret: []byte(secret)})
+}
+
+// redactingWriter removes the secret from the log lines, a whole line is written at once by log
+type redactingWriter struct {
+	w      io.Writer
+	secret []byte
+}
+
+func (rw *redactingWriter) Write(p []byte) (int, error) {
+	if _, err := rw.w.Write(bytes.ReplaceAll(p, rw.secret, []byte(redacted))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"log/slog"
+
+	"github.com/wayan/mergeexp/gitlab"
+)
+
+// createRelease creates GitLab release for already pushed tag
+func createRelease(ctx context.Context, gc *gitlab.Client, projectID int, tag, description string) error {
+	slog.Info("creating GitLab release", "tag", tag)
+	res, err := gc.Req(ctx).
+		SetBody(map[string]string{
+			"tag_name":    tag,
+			"name":        "Release " + tag,
+			"description": description,
+		}).
+		Post(fmt.Sprintf("projects/%d/releases", projectID))
+	if err != nil {
+		return fmt.Errorf("gitlab call failed: %w", err)
+	}
+	if !res.IsSuccess() {
+		return fmt.Errorf("gitlab call returned: %d", res.StatusCode())
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/wayan/mergeexp/git"
+)
+
+// kinds of release version bumps
+const (
+	BumpPatch = "patch"
+	BumpMinor = "minor"
+	BumpMajor = "major"
+)
+
+// nextReleaseVersion returns the version of the new release,
+// either the explicit version which must be greater than the highest one, or the bumped highest version
+func nextReleaseVersion(highest git.VersionTag, bump, explicit string) (git.VersionTag, error) {
+	if explicit != "" {
+		version, err := parseReleaseVersion(explicit)
+		if err != nil {
+			return git.VersionTag{}, err
+		}
+		if compareReleaseVersions(version, highest) <= 0 {
+			return git.VersionTag{}, fmt.Errorf("release version %s is not greater than the highest version tag %s", version, highest)
+		}
+		return version, nil
+	}
+
+	next := git.VersionTag{Major: highest.Major, Minor: highest.Minor, Patch: highest.Patch}
+	switch bump {
+	case BumpPatch:
+		next.Patch++
+	case BumpMinor:
+		next.Minor++
+		next.Patch = 0
+	case BumpMajor:
+		next.Major++
+		next.Minor = 0
+		next.Patch = 0
+	default:
+		return git.VersionTag{}, fmt.Errorf("unknown bump %q, expected one of %s, %s, %s", bump, BumpPatch, BumpMinor, BumpMajor)
+	}
+	return next, nil
+}
+
+// parseReleaseVersion parses version in the format of release tags, i.e. 1.4.3
+func parseReleaseVersion(s string) (git.VersionTag, error) {
+	matches := regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)$`).FindStringSubmatch(s)
+	if matches == nil {
+		return git.VersionTag{}, fmt.Errorf("invalid release version %q, expected MAJOR.MINOR.PATCH", s)
+	}
+	var version git.VersionTag
+	version.Major, _ = strconv.Atoi(matches[1])
+	version.Minor, _ = strconv.Atoi(matches[2])
+	version.Patch, _ = strconv.Atoi(matches[3])
+	return version, nil
+}
+
+func compareReleaseVersions(v1, v2 git.VersionTag) int {
+	if c := cmp.Compare(v1.Major, v2.Major); c != 0 {
+		return c
+	}
+	if c := cmp.Compare(v1.Minor, v2.Minor); c != 0 {
+		return c
+	}
+	return cmp.Compare(v1.Patch, v2.Patch)
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"log/slog"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+// gitLabClientFlags returns flags of GitLab REST API client: TLS connection, timeout and retries
+func gitLabClientFlags(varPrefix string) []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    flags.CACert,
+			Usage:   "PEM file with CA certificates trusted for GitLab REST API in addition to the system ones",
+			Sources: cli.EnvVars(varPrefix + "CA_CERT"),
+		},
+		&cli.StringFlag{
+			Name:    flags.ClientCert,
+			Usage:   "PEM file with client certificate for GitLab REST API, requires client-key",
+			Sources: cli.EnvVars(varPrefix + "CLIENT_CERT"),
+		},
+		&cli.StringFlag{
+			Name:    flags.ClientKey,
+			Usage:   "PEM file with private key of the client certificate",
+			Sources: cli.EnvVars(varPrefix + "CLIENT_KEY"),
+		},
+		&cli.BoolFlag{
+			Name:  flags.InsecureSkipVerify,
+			Usage: "Do not verify TLS certificate of GitLab REST API (insecure)",
+		},
+		&cli.DurationFlag{
+			Name:    flags.GitLabTimeout,
+			Usage:   "Timeout of a single GitLab REST API request",
+			Value:   DefaultGitLabTimeout,
+			Sources: cli.EnvVars(varPrefix + "GITLAB_TIMEOUT"),
+		},
+		&cli.IntFlag{
+			Name:    flags.GitLabRetries,
+			Usage:   "Number of retries of GitLab REST API request failed by network error, 429 or 5xx status",
+			Value:   DefaultGitLabRetries,
+			Sources: cli.EnvVars(varPrefix + "GITLAB_RETRIES"),
+		},
+		&cli.DurationFlag{
+			Name:  flags.GitLabRetryWait,
+			Usage: "Initial wait before retry, doubled with every retry",
+			Value: DefaultGitLabRetryWait,
+		},
+		&cli.DurationFlag{
+			Name:  flags.GitLabRetryMaxWait,
+			Usage: "Maximal wait before retry, the wait asked by Retry-After header is kept up to it, request asking for longer wait is not retried",
+			Value: DefaultGitLabRetryMaxWait,
+		},
+	}
+}
+
+// buildResty returns client of GitLab REST API
+func buildResty(cmd *cli.Command, privateToken string) (*resty.Client, error) {
+	tlsConfig, err := buildTLSConfig(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := resty.New()
+	rc.SetBaseURL(cmd.String(flags.GitLabAPIURL))
+	rc.SetHeader("PRIVATE-TOKEN", privateToken)
+	rc.SetTLSClientConfig(tlsConfig)
+	rc.SetTimeout(cmd.Duration(flags.GitLabTimeout))
+	rc.SetLogger(restyLogger{})
+	retries := cmd.Int(flags.GitLabRetries)
+	rc.SetRetryCount(retries)
+	rc.SetRetryWaitTime(cmd.Duration(flags.GitLabRetryWait))
+	maxWait := cmd.Duration(flags.GitLabRetryMaxWait)
+	rc.SetRetryMaxWaitTime(maxWait)
+	rc.SetRetryAfter(retryAfter)
+	rc.AddRetryCondition(retryTransient(maxWait))
+	rc.AddRetryHook(logRetry(retries))
+	rc.OnAfterResponse(transientStatusError)
+	return rc, nil
+}
+
+// buildTLSConfig returns TLS config from the flags, the certificates are verified unless insecure-skip-verify is set
+func buildTLSConfig(cmd *cli.Command) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cmd.Bool(flags.InsecureSkipVerify) {
+		slog.Warn("TLS certificate of GitLab REST API is not verified", "url", cmd.String(flags.GitLabAPIURL))
+		tlsConfig.InsecureSkipVerify = true
+	}
+
+	if caCert := cmd.String(flags.CACert); caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA certificates: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificate found in %s", caCert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	clientCert, clientKey := cmd.String(flags.ClientCert), cmd.String(flags.ClientKey)
+	if clientCert != "" || clientKey != "" {
+		if clientCert == "" || clientKey == "" {
+			return nil, fmt.Errorf("both %s and %s must be set for client certificate", flags.ClientCert, flags.ClientKey)
+		}
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/wayan/mergeexp/gitdir"
+)
+
+// revParse returns the SHA the revision points to
+func revParse(gd *gitdir.Dir, rev string) (string, error) {
+	out, err := gd.Command("git", "rev-parse", "--verify", rev).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// lsRemoteSHA returns the SHA of ref on remote repo, empty string when the ref does not exist there
+func lsRemoteSHA(gd *gitdir.Dir, url, ref string) (string, error) {
+	out, err := gd.Command("git", "ls-remote", url, ref).Output()
+	if err != nil {
+		return "", err
+	}
+	if fields := strings.Fields(string(out)); len(fields) > 0 {
+		return fields[0], nil
+	}
+	return "", nil
+}
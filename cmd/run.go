@@ -11,7 +11,7 @@ import (
 
 func Run(cli *cli.Command, err error) {
 	if err != nil {
-		slog.Error("building cli failed: %", err.Error(), "exitCode", 1)
+		slog.Error("building cli failed", "error", err.Error(), "exitCode", 1)
 		os.Exit(1)
 	}
 	if err := cli.Run(context.Background(), os.Args); err != nil {
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/urfave/cli/v3"
+	"github.com/wayan/mergeexp/gitdir"
+	"github.com/wayan/oc-mergexp-gl/cmd/flags"
+)
+
+// SSHIdentity is the ssh setup used for git fetches and pushes to remotes with URL matching the pattern
+type SSHIdentity struct {
+	// URLPattern is matched against the whole remote URL, * matches any string
+	URLPattern     string `yaml:"url"`
+	IdentityFile   string `yaml:"identity_file"`
+	KnownHostsFile string `yaml:"known_hosts_file"`
+	// Options are extra ssh options as Key=Value (passed as -o Key=Value)
+	Options []string `yaml:"options"`
+}
+
+const knownHostsOption = "known_hosts"
+
+// String returns the identity in the format of ssh-identity option:
+// pattern=identity_file[;known_hosts=file][;Key=Value...]
+func (id SSHIdentity) String() string {
+	parts := []string{id.IdentityFile}
+	if id.KnownHostsFile != "" {
+		parts = append(parts, knownHostsOption+"="+id.KnownHostsFile)
+	}
+	return id.URLPattern + "=" + strings.Join(append(parts, id.Options...), ";")
+}
+
+// parseSSHIdentity parses pattern=identity_file[;known_hosts=file][;Key=Value...], the identity file may be empty
+func parseSSHIdentity(s string) (SSHIdentity, error) {
+	pattern, rest, found := strings.Cut(s, "=")
+	if !found || pattern == "" {
+		return SSHIdentity{}, fmt.Errorf("invalid ssh identity %q, expected pattern=identity_file[;known_hosts=file][;Key=Value...]", s)
+	}
+	parts := strings.Split(rest, ";")
+	id := SSHIdentity{URLPattern: pattern, IdentityFile: parts[0]}
+	for _, part := range parts[1:] {
+		key, value, found := strings.Cut(part, "=")
+		switch {
+		case !found || key == "":
+			return SSHIdentity{}, fmt.Errorf("invalid ssh option %q in ssh identity %q, expected Key=Value", part, s)
+		case key == knownHostsOption:
+			id.KnownHostsFile = value
+		default:
+			id.Options = append(id.Options, part)
+		}
+	}
+	return id, nil
+}
+
+// sshIdentityFlag returns the ssh-identity flag. The values are not split on commas like in other list flags,
+// ssh options often contain them (Ciphers, KexAlgorithms, ...). Environment variable has one identity per line.
+func sshIdentityFlag(varPrefix string, defaults []SSHIdentity) cli.Flag {
+	specs := &sshIdentitySpecs{}
+	for _, id := range defaults {
+		specs.specs = append(specs.specs, id.String())
+	}
+	return &cli.GenericFlag{
+		Name:    flags.SSHIdentity,
+		Usage:   "SSH identity for remotes with matching URL as pattern=identity_file[;known_hosts=file][;Key=Value...], * in pattern matches anything",
+		Value:   specs,
+		Sources: cli.EnvVars(varPrefix + "SSH_IDENTITIES"),
+	}
+}
+
+// sshIdentitySpecs is the value of ssh-identity flag, the defaults are replaced by the first value set
+type sshIdentitySpecs struct {
+	specs []string
+	set   bool
+}
+
+func (v *sshIdentitySpecs) Set(s string) error {
+	if !v.set {
+		v.specs, v.set = nil, true
+	}
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			v.specs = append(v.specs, line)
+		}
+	}
+	return nil
+}
+
+func (v *sshIdentitySpecs) String() string {
+	return strings.Join(v.specs, " ")
+}
+
+func (v *sshIdentitySpecs) Get() any {
+	return v.specs
+}
+
+// sshIdentitiesFromFlag parses the values of ssh-identity flag
+func sshIdentitiesFromFlag(cmd *cli.Command) (sshIdentities, error) {
+	specs, _ := cmd.Value(flags.SSHIdentity).([]string)
+	return parseSSHIdentities(specs)
+}
+
+// matches reports whether the remote URL matches the pattern of the identity
+func (id SSHIdentity) matches(url string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(id.URLPattern), `\*`, ".*") + "$"
+	matched, _ := regexp.MatchString(expr, url)
+	return matched
+}
+
+// sshCommand returns GIT_SSH_COMMAND using the identity
+func (id SSHIdentity) sshCommand() string {
+	args := []string{"ssh", "-o", "ControlMaster=no", "-o", "BatchMode=yes"}
+	if id.IdentityFile != "" {
+		args = append(args, "-o", "IdentitiesOnly=yes", "-i", id.IdentityFile)
+	}
+	if id.KnownHostsFile != "" {
+		// ssh splits the value on whitespace, the file name is quoted for ssh
+		args = append(args, "-o", "UserKnownHostsFile="+strconv.Quote(id.KnownHostsFile), "-o", "StrictHostKeyChecking=yes")
+	}
+	for _, opt := range id.Options {
+		args = append(args, "-o", opt)
+	}
+
+	// git runs GIT_SSH_COMMAND by shell
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+var shellSafe = regexp.MustCompile(`^[A-Za-z0-9@%+=:,./_-]+$`)
+
+// shellQuote quotes the argument for sh unless it contains only safe characters
+func shellQuote(arg string) string {
+	if shellSafe.MatchString(arg) {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// sshIdentities maps remote URLs to ssh identities, the first matching one is used
+type sshIdentities []SSHIdentity
+
+// parseSSHIdentities parses the values of ssh-identity option and checks the files exist
+func parseSSHIdentities(specs []string) (sshIdentities, error) {
+	var ids sshIdentities
+	for _, spec := range specs {
+		id, err := parseSSHIdentity(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range []string{id.IdentityFile, id.KnownHostsFile} {
+			if _, err := os.Stat(file); file != "" && errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("file %s of ssh identity for %s does not exist", file, id.URLPattern)
+			}
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// identity returns the identity for the remote URL: the matching one,
+// the deploy key if none matches, nil (ssh defaults) if there is no deploy key either
+func (ids sshIdentities) identity(url, deployKey string) *SSHIdentity {
+	for _, id := range ids {
+		if id.matches(url) {
+			return &id
+		}
+	}
+	if deployKey != "" {
+		return &SSHIdentity{IdentityFile: deployKey}
+	}
+	return nil
+}
+
+// dir returns working dir for git commands with the remote URL
+func (ids sshIdentities) dir(gd *gitdir.Dir, url, deployKey string) *gitdir.Dir {
+	return withSSHIdentity(gd, ids.identity(url, deployKey))
+}
+
+// checkDeployKey checks the deploy key file exists, the option is named in the error
+func checkDeployKey(deployKey, option string) error {
+	if _, err := os.Stat(deployKey); errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("file %s with deployment key does not exist, either create it or set different name (see %s option)", deployKey, option)
+	}
+	return nil
+}
+
+// withSSHIdentity returns copy of the working dir which runs git with ssh using the identity,
+// the working dir itself if there is no identity
+func withSSHIdentity(gd *gitdir.Dir, id *SSHIdentity) *gitdir.Dir {
+	if id == nil {
+		return gd
+	}
+
+	env := gd.Env
+	if env == nil {
+		env = os.Environ()
+	}
+	// GIT_SSH_COMMAND must be at the end of the settings
+	// when run go run the GIT_SSH_COMMAND is already set as GIT_SSH_COMMAND=ssh -o ControlMaster=no -o BatchMode=yes
+	return &gitdir.Dir{
+		Dir: gd.Dir,
+		Env: append(slices.Clip(env), "GIT_SSH_COMMAND="+id.sshCommand()),
+	}
+}
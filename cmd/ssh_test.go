@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseSSHIdentity(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    SSHIdentity
+		wantErr bool
+	}{
+		{
+			spec: "git@gitlab.example.com:*=/keys/id",
+			want: SSHIdentity{URLPattern: "git@gitlab.example.com:*", IdentityFile: "/keys/id"},
+		},
+		{
+			spec: "git@*=;known_hosts=/keys/known hosts;Ciphers=aes128-ctr,aes256-ctr",
+			want: SSHIdentity{
+				URLPattern:     "git@*",
+				KnownHostsFile: "/keys/known hosts",
+				Options:        []string{"Ciphers=aes128-ctr,aes256-ctr"},
+			},
+		},
+		{
+			spec: "git@*=/k;ProxyCommand=ssh -W %h:%p jump",
+			want: SSHIdentity{URLPattern: "git@*", IdentityFile: "/k", Options: []string{"ProxyCommand=ssh -W %h:%p jump"}},
+		},
+		{spec: "/keys/id", wantErr: true},
+		{spec: "=/keys/id", wantErr: true},
+		{spec: "git@*=/k;BatchMode", wantErr: true},
+		{spec: "git@*=/k;=yes", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseSSHIdentity(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSSHIdentity(%q) = %+v, want error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSSHIdentity(%q) failed: %v", tt.spec, err)
+			}
+			if got.URLPattern != tt.want.URLPattern || got.IdentityFile != tt.want.IdentityFile ||
+				got.KnownHostsFile != tt.want.KnownHostsFile || !slices.Equal(got.Options, tt.want.Options) {
+				t.Errorf("parseSSHIdentity(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+			if again, err := parseSSHIdentity(got.String()); err != nil || !slices.Equal(again.Options, got.Options) {
+				t.Errorf("parseSSHIdentity(%q) does not round trip: %+v, %v", got.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestSSHCommand(t *testing.T) {
+	common := []string{"ssh", "-o", "ControlMaster=no", "-o", "BatchMode=yes"}
+	tests := []struct {
+		name string
+		id   SSHIdentity
+		want []string
+	}{
+		{
+			name: "identity",
+			id:   SSHIdentity{IdentityFile: "/keys/id"},
+			want: append(slices.Clip(common), "-o", "IdentitiesOnly=yes", "-i", "/keys/id"),
+		},
+		{
+			name: "spaces and quotes in files",
+			id:   SSHIdentity{IdentityFile: "/my keys/bob's id", KnownHostsFile: "/my keys/known hosts"},
+			want: append(slices.Clip(common),
+				"-o", "IdentitiesOnly=yes", "-i", "/my keys/bob's id",
+				"-o", `UserKnownHostsFile="/my keys/known hosts"`, "-o", "StrictHostKeyChecking=yes"),
+		},
+		{
+			name: "shell metacharacters in options",
+			id:   SSHIdentity{Options: []string{"ProxyCommand=ssh -W %h:%p jump", "SetEnv=X=$(touch /tmp/pwned);`id`"}},
+			want: append(slices.Clip(common),
+				"-o", "ProxyCommand=ssh -W %h:%p jump", "-o", "SetEnv=X=$(touch /tmp/pwned);`id`"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// git runs the command by sh, let sh split it back to arguments
+			out, err := exec.Command("sh", "-c", `printf '%s\n' `+tt.id.sshCommand()).Output()
+			if err != nil {
+				t.Fatalf("sh failed on %q: %v", tt.id.sshCommand(), err)
+			}
+			got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("sshCommand() = %s\nsplit by sh to %q\nwant %q", tt.id.sshCommand(), got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,48 @@
 package cmd
 
-type System int
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// System is the name of deployment target, either built-in or defined in the configuration file
+type System string
 
 const (
-	OCP System = iota
-	Cow
+	OCP System = "ocp"
+	Cow System = "cow"
 )
+
+// systemConfigs loads the configuration file once for all the commands of the cli
+var systemConfigs = sync.OnceValues(func() (map[System]SystemConfig, error) {
+	return loadSystemConfigs(configFilePath())
+})
+
+// Config returns the settings of the system, built-in defaults overridden by the configuration file
+func (s System) Config() (SystemConfig, error) {
+	configs, err := systemConfigs()
+	if err != nil {
+		return SystemConfig{}, err
+	}
+	cfg, ok := configs[s]
+	if !ok {
+		return SystemConfig{}, fmt.Errorf("unknown system %q", s)
+	}
+	return cfg, nil
+}
+
+// envPrefix is the prefix of environment variables of the command, e.g. OCP_MERGEXP_
+func (s System) envPrefix(command string) string {
+	return strings.ToUpper(strings.ReplaceAll(string(s), "-", "_") + "_" + command + "_")
+}
+
+// workdir is the default work dir of the command, e.g. ~/.ocp-mergexp
+func (s System) workdir(command string) (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error getting home directory: %v", err)
+	}
+	return homeDir + "/." + string(s) + "-" + command, nil
+}
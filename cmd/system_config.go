@@ -0,0 +1,159 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileEnvVar is environment variable with path to the configuration file
+const ConfigFileEnvVar = "OC_MERGEXP_CONFIG"
+
+// SystemConfig are the settings of a deployment target: GitLab project, test and production environments
+type SystemConfig struct {
+	TargetProjectID     int    `yaml:"target_project_id"`
+	TargetProjectSSHURL string `yaml:"target_project_ssh_url"`
+	GitLabAPIURL        string `yaml:"gitlab_api_url"`
+	// GitLabURL is used in links to merge requests, derived from GitLabAPIURL if empty
+	GitLabURL string `yaml:"gitlab_url"`
+	// StartBranch is the branch experimental is built from
+	StartBranch string `yaml:"start_branch"`
+
+	// DeployTargets are the test environments experimental is pushed to
+	DeployTargets []DeployTarget `yaml:"deploy_targets"`
+
+	ProductionURL    string `yaml:"production_url"`
+	ProductionBranch string `yaml:"production_branch"`
+	// DevelopBranch is harmonized with master by deploy-hotfix, no harmonization if empty
+	DevelopBranch string `yaml:"develop_branch"`
+
+	// DeployKeyName is the file name of deploy key in ~/.ssh
+	DeployKeyName    string   `yaml:"deploy_key_name"`
+	IssueKeyPrefixes []string `yaml:"issue_key_prefixes"`
+
+	// SSHIdentities are ssh setups of the remotes, e.g. scoped keys of test environments
+	SSHIdentities []SSHIdentity `yaml:"ssh_identities"`
+}
+
+// builtinSystems are the defaults for the systems known without configuration file
+var builtinSystems = map[System]SystemConfig{
+	OCP: {
+		TargetProjectID:     OCPTargetProjectID,
+		TargetProjectSSHURL: OCPTargetProjectSSHURL,
+		GitLabAPIURL:        GitLabAPIURL,
+		StartBranch:         Develop,
+		DeployTargets: []DeployTarget{
+			{Name: "TEST1", URL: OCPTest1URL, Branch: Demo},
+			{Name: "TEST2", URL: OCPTest2URL, Branch: DemoTest2},
+		},
+		ProductionURL:    OCPProdURL,
+		ProductionBranch: OCPProductionBranch,
+		DevelopBranch:    Develop,
+		DeployKeyName:    OCPDeploymentKeyName,
+		IssueKeyPrefixes: []string{"OMCTR-"},
+	},
+	Cow: {
+		TargetProjectID:     CowTargetProjectID,
+		TargetProjectSSHURL: CowTargetProjectSSHURL,
+		GitLabAPIURL:        GitLabAPIURL,
+		StartBranch:         Master,
+		DeployTargets: []DeployTarget{
+			{Name: "TEST1", URL: CowTest1URL, Branch: Demo},
+		},
+		ProductionURL:    CowProdURL,
+		ProductionBranch: CowProductionBranch,
+		DeployKeyName:    OCPDeploymentKeyName,
+		IssueKeyPrefixes: []string{"OMCTR-"},
+	},
+}
+
+// configFilePath returns path of the configuration file, from environment or the default one
+func configFilePath() string {
+	if path := os.Getenv(ConfigFileEnvVar); path != "" {
+		return path
+	}
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(configDir, "oc-mergexp-gl", "config.yaml")
+}
+
+// loadSystemConfigs returns built-in systems overridden and extended by the systems from the configuration file.
+// The file looks like
+//
+//	systems:
+//	  ocp:
+//	    deploy_targets:
+//	      - name: TEST1
+//	        url: ocplus@rztvnode404.cz.tmo:~/deploy/git/OCP.git
+//	        branch: demo
+//	      - name: TEST3
+//	        url: ocplus@rztvnode405.cz.tmo:~/deploy/git/OCP.git
+//	        branch: demo
+//	  foo:
+//	    target_project_id: 6100
+//	    ...
+//
+// Only the settings present in the file are overridden. The lists (deploy_targets, ssh_identities,
+// issue_key_prefixes) replace the built-in ones, they are not appended to them.
+// Missing file is not an error, only built-in systems are returned.
+func loadSystemConfigs(path string) (map[System]SystemConfig, error) {
+	configs := make(map[System]SystemConfig, len(builtinSystems))
+	for s, cfg := range builtinSystems {
+		configs[s] = cfg
+	}
+	if path == "" {
+		return configs, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return configs, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading configuration file: %w", err)
+	}
+
+	// the settings are decoded strictly first to report misspelled keys with their lines,
+	// yaml.Node.Decode used for overriding ignores unknown keys
+	var strict configFile
+	if err := decodeStrict(data, &strict); err != nil {
+		return nil, fmt.Errorf("parsing configuration file %s: %w", path, err)
+	}
+	var file struct {
+		Systems map[System]yaml.Node `yaml:"systems"`
+	}
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing configuration file %s: %w", path, err)
+	}
+	for s, node := range file.Systems {
+		// only the settings present in the file override the built-in ones
+		cfg := configs[s]
+		if err := node.Decode(&cfg); err != nil {
+			return nil, fmt.Errorf("parsing system %s in configuration file %s: %w", s, path, err)
+		}
+		configs[s] = cfg
+	}
+	return configs, nil
+}
+
+// configFile is the content of the configuration file
+type configFile struct {
+	Systems map[System]SystemConfig `yaml:"systems"`
+}
+
+// decodeStrict decodes YAML reporting the keys not matching any field (a misspelled setting)
+func decodeStrict(data []byte, v any) error {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
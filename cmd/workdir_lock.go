@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"log/slog"
+)
+
+// lockFileName is the lock file in .git of work dir, outside of the working tree
+const lockFileName = "oc-mergexp.lock"
+
+// lockInfo is the content of the lock file telling who holds the lock
+type lockInfo struct {
+	Host    string    `json:"host"`
+	PID     int       `json:"pid"`
+	Started time.Time `json:"started"`
+}
+
+func (li lockInfo) String() string {
+	return fmt.Sprintf("process %d on %s since %s", li.PID, li.Host, li.Started.Format(time.DateTime))
+}
+
+// stale reports whether the lock was left by a dead process on this host
+func (li lockInfo) stale() bool {
+	host, _ := os.Hostname()
+	if li.Host != host || li.PID <= 0 {
+		// process on another host cannot be checked
+		return false
+	}
+	return processDead(li.PID)
+}
+
+func (li lockInfo) equal(other lockInfo) bool {
+	return li.Host == other.Host && li.PID == other.PID && li.Started.Equal(other.Started)
+}
+
+// workdirLock is exclusive lock of work dir preventing concurrent runs
+type workdirLock struct {
+	path string
+}
+
+// lockWorkdir locks the work dir, stale locks of dead processes are removed.
+// If the lock is held by other process, it either fails or with wait polls until the lock is free.
+func lockWorkdir(ctx context.Context, workdir string, wait bool) (*workdirLock, error) {
+	path := filepath.Join(workdir, ".git", lockFileName)
+	host, _ := os.Hostname()
+	data, err := json.Marshal(lockInfo{Host: host, PID: os.Getpid(), Started: time.Now()})
+	if err != nil {
+		return nil, err
+	}
+
+	for logged := false; ; {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = f.Write(data)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("writing lock file %s: %w", path, err)
+			}
+			return &workdirLock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("creating lock file %s: %w", path, err)
+		}
+
+		holder, err := readLockInfo(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				// released in the meantime
+				continue
+			}
+			return nil, err
+		}
+		if holder.stale() {
+			if err := removeStaleLock(path, holder); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if !wait {
+			return nil, fmt.Errorf("work dir %s is locked by %s, see lock file %s (use wait option to wait for it)", workdir, holder, path)
+		}
+
+		if !logged {
+			slog.Info("waiting for work dir lock", "dir", workdir, "holder", holder.String())
+			logged = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// removeStaleLock removes the lock file of the dead holder. Other waiter may have replaced it by its own lock
+// in the meantime, so the file is renamed away first and put back if it is not the stale one.
+func removeStaleLock(path string, holder lockInfo) error {
+	moved := path + ".stale." + strconv.Itoa(os.Getpid())
+	if err := os.Rename(path, moved); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// removed by other waiter
+			return nil
+		}
+		return fmt.Errorf("removing stale lock file %s: %w", path, err)
+	}
+	defer os.Remove(moved)
+
+	if current, err := readLockInfo(moved); err != nil || !current.equal(holder) {
+		// fresh (maybe not yet written) lock of other waiter, it is put back unless yet another lock was created
+		if err := os.Link(moved, path); err != nil {
+			slog.Warn("restoring lock file failed", "lock", path, "error", err)
+		}
+		return nil
+	}
+	slog.Warn("removed stale lock of dead process", "lock", path, "holder", holder.String())
+	return nil
+}
+
+func readLockInfo(path string) (lockInfo, error) {
+	var li lockInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return li, err
+	}
+	if err := json.Unmarshal(data, &li); err != nil {
+		return li, fmt.Errorf("invalid lock file %s, remove it if no other run is in progress: %w", path, err)
+	}
+	return li, nil
+}
+
+// unlock releases the lock
+func (l *workdirLock) unlock() {
+	if err := os.Remove(l.path); err != nil {
+		slog.Warn("removing lock file failed", "lock", l.path, "error", err)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package cmd
+
+// processDead cannot check the process, the lock is never considered stale
+func processDead(pid int) bool {
+	return false
+}